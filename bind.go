@@ -0,0 +1,177 @@
+package slimfig
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// A FlagSet is a set of command-line flags from either the standard library
+// or github.com/spf13/pflag.
+type FlagSet interface {
+	*flag.FlagSet | *pflag.FlagSet
+}
+
+// A FlagMapper maps a flag name to a dotted configuration key. Returning an
+// empty string leaves the flag unbound.
+type FlagMapper func(name string) string
+
+// DefaultFlagMapper replaces dashes by underscores, so flag "max-conns" binds
+// to key "max_conns" and flag "db.max-conns" to key "db.max_conns".
+func DefaultFlagMapper(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+var flagMapper FlagMapper = DefaultFlagMapper
+
+// SetFlagMapper sets the mapper from flag names to configuration keys used by
+// BindFlags. Passing nil restores DefaultFlagMapper.
+func SetFlagMapper(m FlagMapper) {
+	if m == nil {
+		m = DefaultFlagMapper
+	}
+	flagMapper = m
+}
+
+// BindFlags binds the flags in the flag set to configuration keys (see
+// SetFlagMapper). It should be called after Load and after the flag set has
+// been parsed.
+//
+// Flags that have been set explicitly override the configuration, with their
+// values converted like EnvCoerce. Other flags take their value and default
+// from the configuration, so variables bound to flags hold the configured
+// values. When the configuration lacks the key, the flag's default is added
+// to it. The resulting order of precedence is:
+//
+//  1. flags set explicitly
+//  2. "--set" flags (see SetFlag)
+//  3. environment variables of keys declared with Define (see Env)
+//  4. environment variables starting with the prefix passed to Load
+//  5. the configuration scheme
+//  6. flag defaults
+//
+// The flags registered by RegisterFlags are not bound. When a flag cannot be
+// bound, the flags bound before it are restored and the configuration is left
+// unchanged.
+func BindFlags[T FlagSet](fs T) error {
+	// the configuration is only updated once all flags have been bound
+	var writes, bound []boundFlag
+	for _, f := range boundFlags(fs) {
+		if f.name == ConfigFlag || f.name == SetFlag {
+			continue
+		}
+		key := flagMapper(f.name)
+		if key == "" {
+			continue
+		}
+		f.parts = strings.Split(key, ".")
+		write, err := f.bind()
+		if err != nil {
+			f.restore()
+			for i := len(bound) - 1; i >= 0; i -= 1 {
+				bound[i].restore()
+			}
+			return fmt.Errorf("error binding flag %q to %q: %w", f.name, key, err)
+		}
+		if write {
+			writes = append(writes, f)
+		} else {
+			bound = append(bound, f)
+		}
+	}
+	for _, f := range writes {
+		existing, exists := config.get2(f.parts)
+		v := typedValue(EnvCoerce, f.value.String(), existing, exists)
+		config = setAt(map[string]any(config), f.parts, v).(map[string]any)
+	}
+	return nil
+}
+
+// boundFlag abstracts the flags of both flag libraries.
+type boundFlag struct {
+	name     string
+	value    flag.Value
+	changed  bool
+	defValue *string
+	parts    []string
+
+	// the value and default before binding
+	prev      string
+	prevSlice []string
+	prevDef   string
+}
+
+func boundFlags[T FlagSet](fs T) []boundFlag {
+	var out []boundFlag
+	switch fs := any(fs).(type) {
+	case *flag.FlagSet:
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) {
+			set[f.Name] = true
+		})
+		fs.VisitAll(func(f *flag.Flag) {
+			out = append(out, boundFlag{name: f.Name, value: f.Value, changed: set[f.Name], defValue: &f.DefValue})
+		})
+	case *pflag.FlagSet:
+		fs.VisitAll(func(f *pflag.Flag) {
+			out = append(out, boundFlag{name: f.Name, value: f.Value, changed: f.Changed, defValue: &f.DefValue})
+		})
+	}
+	return out
+}
+
+// bind sets the flag to the configuration value at its key parts. Returns
+// true if the flag's value should be written to the configuration instead.
+func (f *boundFlag) bind() (bool, error) {
+	existing, exists := config.get2(f.parts)
+	if f.changed || !exists {
+		return true, nil
+	}
+	f.prev, f.prevDef = f.value.String(), *f.defValue
+	if sv, ok := f.value.(pflag.SliceValue); ok {
+		f.prevSlice = sv.GetSlice()
+	}
+	if err := f.set(existing); err != nil {
+		return false, err
+	}
+	*f.defValue = f.value.String()
+	return false, nil
+}
+
+// set sets the flag's value to the configuration value.
+func (f boundFlag) set(v any) error {
+	xs, ok := toSlice(v, flagString)
+	if !ok {
+		s, _ := flagString(v)
+		return f.value.Set(s)
+	}
+	if sv, ok := f.value.(pflag.SliceValue); ok {
+		return sv.Replace(xs)
+	}
+	return f.value.Set(strings.Join(xs, ","))
+}
+
+// restore resets the flag's value and default to those before binding.
+func (f boundFlag) restore() {
+	if sv, ok := f.value.(pflag.SliceValue); ok {
+		_ = sv.Replace(f.prevSlice)
+	} else {
+		_ = f.value.Set(f.prev)
+	}
+	*f.defValue = f.prevDef
+}
+
+// flagString formats the value for parsing by a flag. Unlike "%v", floats are
+// never formatted with an exponent, so 1000000 can be set on integer flags.
+func flagString(a any) (string, bool) {
+	switch x := a.(type) {
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32), true
+	}
+	return toString2(a)
+}
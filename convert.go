@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 )
 
 func toString(a any) string {
@@ -67,6 +68,21 @@ func toBool(a any) (bool, bool) {
 	return b, true
 }
 
+func toDuration(a any) (time.Duration, bool) {
+	if d, ok := a.(time.Duration); ok {
+		return d, true
+	}
+	s, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
+
 func toAny(a any) (any, bool) {
 	return a, true
 }
@@ -76,12 +92,12 @@ func toSlice[T any](a any, conv func(a any) (T, bool)) ([]T, bool) {
 		return out, true
 	}
 	v := reflect.ValueOf(a)
-	if v.Type().Kind() != reflect.Slice {
+	if v.Kind() != reflect.Slice {
 		return nil, false
 	}
 	var out []T
 	for i := 0; i < v.Len(); i += 1 {
-		if v2, ok := conv(v.Index(i)); ok {
+		if v2, ok := conv(v.Index(i).Interface()); ok {
 			out = append(out, v2)
 		}
 	}
@@ -93,7 +109,7 @@ func toMap[T any](a any, conv func(a any) (T, bool)) (map[string]T, bool) {
 		return out, true
 	}
 	v := reflect.ValueOf(a)
-	if v.Type().Kind() != reflect.Map {
+	if v.Kind() != reflect.Map {
 		return nil, false
 	}
 	if v.Len() == 0 {
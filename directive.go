@@ -0,0 +1,168 @@
+package slimfig
+
+import (
+	"maps"
+	"slices"
+)
+
+const (
+	// DirectiveReplace, when set to true in a map, replaces the existing
+	// map instead of merging into it:
+	//
+	//	{"target": {"$replace": true, "host": "example.com"}}
+	DirectiveReplace = "$replace"
+	// DirectiveAppend appends items to an existing list:
+	//
+	//	{"schemes": {"$append": ["grpc"]}}
+	DirectiveAppend = "$append"
+	// DirectivePrepend prepends items to an existing list:
+	//
+	//	{"schemes": {"$prepend": ["grpc"]}}
+	DirectivePrepend = "$prepend"
+	// DirectiveMergeKey merges the items under DirectiveItems into an
+	// existing list of maps, matching items by the value of the given key:
+	//
+	//	{"upstreams": {"$mergeKey": "name", "$items": [{"name": "a", "timeout_s": 5}]}}
+	//
+	// Matching items are merged, others are appended. See also SetMergeKeys.
+	DirectiveMergeKey = "$mergeKey"
+	// DirectiveItems holds the items for DirectiveMergeKey.
+	DirectiveItems = "$items"
+)
+
+var mergeKeys = map[string]string{}
+
+// SetMergeKeys declares lists of maps that should be merged by an identity
+// key, rather than replaced. The map keys are the dotted paths of the lists,
+// the values the identity keys of their items. For instance:
+//
+//	slimfig.SetMergeKeys(map[string]string{"upstreams": "name"})
+//
+// Overriding items with the same "name" are merged into the existing items,
+// other items are appended. List indices are left out of the paths, so a
+// nested list is declared as "upstreams.endpoints", which applies to the
+// "endpoints" list of every upstream. Must be called before Load.
+func SetMergeKeys(keys map[string]string) {
+	mergeKeys = keys
+}
+
+// keyedMerge merges the items into the existing list, matching them by the
+// value of the key. Returns false if the items are not a list.
+func keyedMerge(old any, items any, key, path string) ([]any, bool) {
+	xs, ok := toSlice(items, toAny)
+	if !ok {
+		return nil, false
+	}
+	var out []any
+	if old != nil {
+		if base, ok := toSlice(old, toAny); ok {
+			out = slices.Clone(base)
+		}
+	}
+	for _, x := range xs {
+		xm, ok := x.(map[string]any)
+		if !ok {
+			out = append(out, withoutDirectives(x))
+			continue
+		}
+		i := indexByKey(out, key, xm[key])
+		if i < 0 {
+			out = append(out, withoutDirectives(xm))
+			continue
+		}
+		m, ok := out[i].(map[string]any)
+		if !ok {
+			m, _ = toMap(out[i], toAny)
+		}
+		cm := configMap(maps.Clone(m))
+		if cm == nil {
+			cm = configMap{}
+		}
+		// list indices are left out of the path
+		mergeAt(&cm, xm, path)
+		out[i] = map[string]any(cm)
+	}
+	return out, true
+}
+
+// indexByKey returns the index of the first map in the list with the given
+// value at the key, or -1 if there is none.
+func indexByKey(xs []any, key string, v any) int {
+	if v == nil {
+		return -1
+	}
+	for i := range xs {
+		m, ok := xs[i].(map[string]any)
+		if !ok {
+			if m, ok = toMap(xs[i], toAny); !ok {
+				continue
+			}
+		}
+		if x, ok := m[key]; ok && toString(x) == toString(v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// listDirective applies the append and prepend directives in the map to the
+// existing value. Returns false if the map contains neither.
+func listDirective(old any, m map[string]any) ([]any, bool) {
+	app, hasApp := m[DirectiveAppend]
+	pre, hasPre := m[DirectivePrepend]
+	if !hasApp && !hasPre {
+		return nil, false
+	}
+	var out []any
+	if hasPre {
+		out = append(out, directiveItems(pre)...)
+	}
+	if old != nil {
+		if xs, ok := toSlice(old, toAny); ok {
+			out = append(out, xs...)
+		}
+	}
+	if hasApp {
+		out = append(out, directiveItems(app)...)
+	}
+	return out, true
+}
+
+func directiveItems(v any) []any {
+	xs, ok := toSlice(v, toAny)
+	if !ok {
+		return []any{withoutDirectives(v)}
+	}
+	out := slices.Clone(xs)
+	for i := range out {
+		out[i] = withoutDirectives(out[i])
+	}
+	return out
+}
+
+// withoutDirectives returns the value as it would be when merged into an
+// empty map: directives are applied, while keys with null values are kept.
+// Maps are copied.
+func withoutDirectives(v any) any {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	if _, ok := m[DirectiveMergeKey]; ok {
+		if m[DirectiveItems] == nil {
+			return []any{}
+		}
+		return directiveItems(m[DirectiveItems])
+	}
+	if xs, ok := listDirective(nil, m); ok {
+		return xs
+	}
+	out := make(map[string]any, len(m))
+	for k, x := range m {
+		if k == DirectiveReplace {
+			continue
+		}
+		out[k] = withoutDirectives(x)
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package slimfig
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Discovery describes where to look for configuration files when the
+// configuration scheme is empty. See SetDiscovery.
+type Discovery struct {
+	// Dirs are the directories to search, in order of increasing
+	// precedence.
+	Dirs []string
+	// Names are the file names to look for, without extension.
+	Names []string
+	// Extensions are the file extensions to look for, including the dot.
+	Extensions []string
+}
+
+// DefaultDiscovery returns a Discovery for the given application name. It
+// searches, in order of increasing precedence:
+//   - /etc/<app>
+//   - $XDG_CONFIG_HOME/<app> (defaulting to $HOME/.config/<app>)
+//   - the working directory
+//
+// for files named config.json, config.yaml or config.toml.
+func DefaultDiscovery(app string) Discovery {
+	dirs := []string{filepath.Join("/etc", app)}
+	if dir := configHome(); dir != "" {
+		dirs = append(dirs, filepath.Join(dir, app))
+	}
+	dirs = append(dirs, ".")
+	return Discovery{
+		Dirs:       dirs,
+		Names:      []string{"config"},
+		Extensions: []string{".json", ".yaml", ".toml"},
+	}
+}
+
+func configHome() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config")
+	}
+	return ""
+}
+
+var discovery *Discovery
+
+// SetDiscovery enables the discovery of configuration files. When Load is
+// called without references and the configuration scheme environment variable
+// is empty, the discovered files will be used as the configuration scheme.
+// Passing nil disables discovery, which is the default.
+//
+// Only files that can be handled by one of the resolvers are considered, so
+// resolvers must be set (see SetResolvers) for all extensions of interest.
+// Which files were used can be found in the report (see Report).
+func SetDiscovery(d *Discovery) {
+	discovery = d
+}
+
+// Find returns the paths of the existing configuration files that can be
+// handled by one of the resolvers, in order of increasing precedence.
+func (d Discovery) Find() []string {
+	var found []string
+	for _, dir := range d.Dirs {
+		for _, name := range d.Names {
+			for _, ext := range d.Extensions {
+				p := filepath.Join(dir, name+ext)
+				if findResolver(p) == nil {
+					continue
+				}
+				if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+					found = append(found, p)
+				}
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,281 @@
+package slimfig
+
+import (
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// An EnvMapper maps the name of an environment variable, stripped of its
+// prefix, to the key parts of the configuration value it overrides. The
+// configuration loaded so far is passed along, so mappers can match against
+// existing keys. Returning no parts skips the variable.
+type EnvMapper func(name string, cfg map[string]any) []string
+
+// DefaultEnvMapper splits names on double underscores and preserves case, so
+// PREFIX_a__b overrides the key "a.b".
+var DefaultEnvMapper = SeparatorMapper("__")
+
+var envMapper = DefaultEnvMapper
+
+// SetEnvMapper sets the mapper for environment variables. Passing nil
+// restores DefaultEnvMapper. Must be called before Load.
+//
+// Mappers can be combined, for instance:
+//
+//	slimfig.SetEnvMapper(slimfig.CaseInsensitiveMapper(slimfig.SeparatorMapper("__")))
+func SetEnvMapper(m EnvMapper) {
+	if m == nil {
+		m = DefaultEnvMapper
+	}
+	envMapper = m
+}
+
+// SeparatorMapper returns a mapper that splits names on the separator.
+func SeparatorMapper(sep string) EnvMapper {
+	return func(name string, _ map[string]any) []string {
+		return strings.Split(name, sep)
+	}
+}
+
+// LowercaseMapper returns a mapper that lowercases names before passing them
+// on to the given mapper.
+func LowercaseMapper(m EnvMapper) EnvMapper {
+	return func(name string, cfg map[string]any) []string {
+		return m(strings.ToLower(name), cfg)
+	}
+}
+
+// CaseInsensitiveMapper returns a mapper that matches the key parts produced
+// by the given mapper case-insensitively against existing keys, so that
+// PREFIX_TIMEOUT_S overrides "timeout_s". Parts without a matching key are
+// kept as they are.
+func CaseInsensitiveMapper(m EnvMapper) EnvMapper {
+	return func(name string, cfg map[string]any) []string {
+		parts := m(name, cfg)
+		var node any = cfg
+		for i, p := range parts {
+			k, v, ok := findKeyFold(node, p)
+			if !ok {
+				break
+			}
+			parts[i] = k
+			node = v
+		}
+		return parts
+	}
+}
+
+// KeyTreeMapper returns a mapper that splits names on the separator, i.e.
+// "_", guided by the existing keys. At every level, the longest run of name
+// segments that matches an existing key (case-insensitively) is taken as the
+// key, so that with separator "_", PREFIX_DB_MAX_CONNS overrides
+// "db.max_conns" if "db" has a key "max_conns". The remainder of a name
+// without matching keys becomes a single key.
+func KeyTreeMapper(sep string) EnvMapper {
+	return func(name string, cfg map[string]any) []string {
+		segments := strings.Split(name, sep)
+		var parts []string
+		var node any = cfg
+		for len(segments) > 0 {
+			k, v, n := longestKey(node, segments, sep)
+			if n == 0 {
+				return append(parts, strings.Join(segments, sep))
+			}
+			parts = append(parts, k)
+			node = v
+			segments = segments[n:]
+		}
+		return parts
+	}
+}
+
+// longestKey finds the key in the node matching the longest run of leading
+// segments, joined by the separator. Returns the key, its value and the
+// number of segments matched.
+func longestKey(node any, segments []string, sep string) (string, any, int) {
+	for n := len(segments); n > 0; n -= 1 {
+		if k, v, ok := findKeyFold(node, strings.Join(segments[:n], sep)); ok {
+			return k, v, n
+		}
+	}
+	return "", nil, 0
+}
+
+// findKeyFold finds the key in the node equal to s under case-folding,
+// preferring an exact match. For lists, s must be an index of an element.
+// Returns false if the node has no such key or element.
+func findKeyFold(node any, s string) (string, any, bool) {
+	if xs, ok := toSlice(node, toAny); ok {
+		if i, ok := listIndex(s, len(xs)-1); ok {
+			return s, xs[i], true
+		}
+		return "", nil, false
+	}
+	m, ok := node.(map[string]any)
+	if !ok {
+		if m, ok = toMap(node, toAny); !ok {
+			return "", nil, false
+		}
+	}
+	if v, ok := m[s]; ok {
+		return s, v, true
+	}
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if strings.EqualFold(k, s) {
+			return k, m[k], true
+		}
+	}
+	return "", nil, false
+}
+
+// EnvTyping determines how environment variable values are typed.
+type EnvTyping int
+
+const (
+	// EnvStrings stores values as strings. This is the default.
+	EnvStrings EnvTyping = iota
+	// EnvParse parses values as JSON or YAML, so "8080" becomes an integer
+	// and `["a","b"]` a list. Values that cannot be parsed, as well as
+	// "null", remain strings.
+	EnvParse
+	// EnvCoerce converts values to the type of the value they override, so
+	// "8080" remains a string when overriding a string, but becomes a number
+	// when overriding one. Values for new keys, lists and maps, and values
+	// that cannot be converted, are parsed as with EnvParse.
+	EnvCoerce
+)
+
+var envTyping = EnvStrings
+
+// SetEnvTyping sets how environment variable values are typed. Must be called
+// before Load.
+func SetEnvTyping(t EnvTyping) {
+	envTyping = t
+}
+
+// envValue types the value of an environment variable that overrides the
+// given existing value, if any.
+func envValue(s string, old any, exists bool) any {
+	return typedValue(envTyping, s, old, exists)
+}
+
+// typedValue types the string according to the typing.
+func typedValue(typing EnvTyping, s string, old any, exists bool) any {
+	switch typing {
+	case EnvParse:
+		return parseEnvValue(s)
+	case EnvCoerce:
+		if exists {
+			if v, ok := coerce(s, old); ok {
+				return v
+			}
+		}
+		return parseEnvValue(s)
+	}
+	return s
+}
+
+// coerce converts the string to the type of the existing value. Returns false
+// for lists, maps and values that cannot be converted.
+func coerce(s string, old any) (any, bool) {
+	switch reflect.ValueOf(old).Kind() {
+	case reflect.String:
+		return s, true
+	case reflect.Bool:
+		return toBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return toInt(s)
+	case reflect.Float32, reflect.Float64:
+		return toFloat64(s)
+	}
+	return nil, false
+}
+
+// parseEnvValue parses the string as a JSON or YAML value. Lists and maps
+// must be flow collections, so values like "a: b" remain strings. Scalars are
+// parsed as booleans ("true" and "false") and decimal numbers only, so values
+// like "0123" and "2024-01-01" remain strings as well.
+func parseEnvValue(s string) any {
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return s
+	}
+	switch t[0] {
+	case '[', '{':
+		var v any
+		if err := yaml.Unmarshal([]byte(t), &v); err != nil {
+			return s
+		}
+		return v
+	}
+	switch t {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if digits := strings.TrimPrefix(t, "-"); len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return s
+	}
+	if i, err := strconv.Atoi(t); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(t, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+	return s
+}
+
+// setAt sets the value at the key parts in the node and returns the updated
+// node. Parts that are indices of existing lists address their elements,
+// where an index equal to the list's length appends. Other parts are map
+// keys. Maps set at an existing map are merged into it.
+func setAt(node any, parts []string, v any) any {
+	if len(parts) == 0 {
+		vm, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		m, ok := toMap(node, toAny)
+		if !ok {
+			return v
+		}
+		out := configMap(m)
+		if out == nil {
+			out = configMap{}
+		}
+		merge(&out, vm)
+		return map[string]any(out)
+	}
+	if xs, ok := toSlice(node, toAny); ok {
+		if i, ok := listIndex(parts[0], len(xs)); ok {
+			xs = slices.Clone(xs)
+			if i == len(xs) {
+				return append(xs, setAt(nil, parts[1:], v))
+			}
+			xs[i] = setAt(xs[i], parts[1:], v)
+			return xs
+		}
+	}
+	m, ok := toMap(node, toAny)
+	if !ok || m == nil {
+		m = map[string]any{}
+	}
+	m[parts[0]] = setAt(m[parts[0]], parts[1:], v)
+	return m
+}
+
+// listIndex parses s as a list index no greater than max.
+func listIndex(s string, max int) (int, bool) {
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 || i > max || strconv.Itoa(i) != s {
+		return 0, false
+	}
+	return i, true
+}
@@ -4,12 +4,12 @@ func Merge(old *map[string]any, v map[string]any) {
 	merge((*configMap)(old), v)
 }
 
-func LoadEnvironment(prefix string) {
-	loadEnvironment(prefix)
+func LoadEnvironment(prefix string) []*EnvError {
+	return loadEnvironment(prefix)
 }
 
 func AddEnv(old *map[string]any, k string, v string) {
-	addEnv((*configMap)(old), k, v)
+	addEnv((*configMap)(old), envMapper(k, *old), v)
 }
 
 func Config() map[string]any {
@@ -23,3 +23,11 @@ func SetConfig(m map[string]any) {
 func Reset() {
 	reset()
 }
+
+func Interpolate(m map[string]any) error {
+	return interpolate(m)
+}
+
+func ResetRegistry() {
+	registry = map[string]*Definition{}
+}
@@ -0,0 +1,134 @@
+package slimfig
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+const (
+	// ConfigFlag is the command-line flag holding the configuration scheme,
+	// i.e. "--config a.json,b.yaml". It takes precedence over the scheme
+	// environment variable and the references passed to Load. The flag can
+	// be repeated.
+	ConfigFlag = "config"
+	// SetFlag is the command-line flag overriding a single (dotted) key,
+	// i.e. "--set target.host=example.com". The flag can be repeated.
+	SetFlag = "set"
+)
+
+// cliArgs returns the values of the configuration flags.
+var cliArgs func() (scheme []string, set []string, err error)
+
+// SetArgs makes Load read the configuration flags from the command-line
+// arguments, i.e.:
+//
+//	slimfig.SetArgs(os.Args[1:])
+//
+// Other arguments are ignored, as are all arguments after "--". Passing nil
+// stops reading the flags. Must be called before Load.
+func SetArgs(args []string) {
+	if args == nil {
+		cliArgs = nil
+		return
+	}
+	cliArgs = func() ([]string, []string, error) {
+		return parseArgs(args)
+	}
+}
+
+// RegisterFlags defines the configuration flags on the flag set. Load uses
+// their values after the flag set has been parsed:
+//
+//	slimfig.RegisterFlags(flag.CommandLine)
+//	flag.Parse()
+//	err := slimfig.Load(ctx, "XX")
+func RegisterFlags(fs *flag.FlagSet) {
+	scheme := &listFlag{split: true}
+	set := &listFlag{}
+	fs.Var(scheme, ConfigFlag, "comma-separated configuration `references`")
+	fs.Var(set, SetFlag, "override a configuration value, as `key=value`")
+	cliArgs = func() ([]string, []string, error) {
+		return scheme.values, set.values, nil
+	}
+}
+
+// flagValues returns the values of the configuration flags, if these are
+// used.
+func flagValues() ([]string, []string, error) {
+	if cliArgs == nil {
+		return nil, nil, nil
+	}
+	return cliArgs()
+}
+
+// listFlag is a repeatable flag.
+type listFlag struct {
+	values []string
+	split  bool
+}
+
+func (f *listFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.values, ",")
+}
+
+func (f *listFlag) Set(s string) error {
+	if f.split {
+		f.values = append(f.values, strings.Split(s, ",")...)
+	} else {
+		f.values = append(f.values, s)
+	}
+	return nil
+}
+
+// parseArgs collects the values of the configuration flags. Flags can start
+// with one or two dashes and their values can be passed as separate
+// arguments or after an "=".
+func parseArgs(args []string) ([]string, []string, error) {
+	scheme := &listFlag{split: true}
+	set := &listFlag{}
+	for i := 0; i < len(args); i += 1 {
+		if args[i] == "--" {
+			break
+		}
+		a, ok := strings.CutPrefix(args[i], "-")
+		if !ok {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(a, "-"), "=")
+		if name != ConfigFlag && name != SetFlag {
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("flag needs an argument: %s", args[i])
+			}
+			i += 1
+			value = args[i]
+		}
+		if name == ConfigFlag {
+			_ = scheme.Set(value)
+		} else {
+			_ = set.Set(value)
+		}
+	}
+	return scheme.values, set.values, nil
+}
+
+// applySetFlags applies the "--set" overrides to the configuration. Values
+// are typed as with EnvCoerce.
+func applySetFlags(set []string) error {
+	for _, kv := range set {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid --%s %q: expected key=value", SetFlag, kv)
+		}
+		parts := strings.Split(k, ".")
+		existing, exists := config.get2(parts)
+		config = setAt(map[string]any(config), parts, typedValue(EnvCoerce, v, existing, exists)).(map[string]any)
+	}
+	return nil
+}
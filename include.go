@@ -0,0 +1,99 @@
+package slimfig
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// IncludeKey is the reserved top-level key for including other configuration
+// maps, i.e.:
+//
+//	{"$include": ["common.yaml", "gcp-secretmanager://projects/1/secrets/x"]}
+//
+// Included references are resolved with the registered resolvers and merged
+// in order, after which the including map is merged on top of them. Relative
+// file paths are resolved against the directory of the including file.
+// Includes may be nested up to MaxIncludeDepth levels.
+const IncludeKey = "$include"
+
+// MaxIncludeDepth is the maximum nesting depth of includes.
+const MaxIncludeDepth = 10
+
+// includes expands the includes of the resolved map. The chain holds the
+// targets of the including references, starting with the one for the map
+// itself.
+func includes(ctx context.Context, cfg map[string]any, chain []string) (map[string]any, error) {
+	v, ok := cfg[IncludeKey]
+	if !ok {
+		return cfg, nil
+	}
+	refs, ok := includeReferences(v)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a string or a list of strings", IncludeKey)
+	}
+	if len(chain) > MaxIncludeDepth {
+		return nil, fmt.Errorf("maximum include depth of %d exceeded", MaxIncludeDepth)
+	}
+
+	out := configMap{}
+	for _, s := range refs {
+		ref := parseReference(s)
+		if ref.patch {
+			return nil, fmt.Errorf("cannot include patch %q", ref.raw)
+		}
+		ref.target = relativeTo(chain[len(chain)-1], ref.target)
+		if slices.Contains(chain, ref.target) {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), ref.target)
+		}
+		r := findResolver(ref.target)
+		if r == nil {
+			return nil, fmt.Errorf("no resolver for included %q", ref.raw)
+		}
+		m, err := resolveChain(ctx, r, ref, append(slices.Clip(chain), ref.target))
+		if err != nil {
+			return nil, fmt.Errorf("error including %q: %w", ref.raw, err)
+		}
+		merge(&out, m)
+	}
+	own := maps.Clone(cfg)
+	delete(own, IncludeKey)
+	merge(&out, own)
+	return out, nil
+}
+
+func includeReferences(v any) ([]string, bool) {
+	switch x := v.(type) {
+	case string:
+		return []string{x}, true
+	case []string:
+		return x, true
+	case []any:
+		out := make([]string, len(x))
+		for i := range x {
+			s, ok := x[i].(string)
+			if !ok {
+				return nil, false
+			}
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// relativeTo resolves a relative file path target against the directory of the
+// including target. Other targets are returned as is.
+func relativeTo(including, target string) string {
+	if strings.Contains(target, "://") || filepath.IsAbs(target) {
+		return target
+	}
+	dir := strings.TrimPrefix(including, "file://")
+	if strings.Contains(dir, "://") {
+		return target
+	}
+	return filepath.Join(filepath.Dir(dir), target)
+}
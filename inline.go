@@ -0,0 +1,144 @@
+package slimfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/HayoVanLoon/go-slimfig/resolver"
+)
+
+// InlinePrefix marks a string value as an inline reference, i.e.:
+//
+//	{"password": "ref+gcp-secretmanager://projects/1/secrets/db-pass"}
+//
+// The value is replaced by the raw data of the referenced resource, or, when
+// the reference is followed by a "#path" fragment, by the value at that
+// (dotted) path in the resolved map.
+const InlinePrefix = "ref+"
+
+type inlineKey struct {
+	target string
+	raw    bool
+}
+
+type inlineSite struct {
+	path   string
+	target string
+	key    string
+	set    func(any)
+}
+
+// resolveInline replaces the inline references in the configuration. Every
+// referenced resource is only fetched once.
+func resolveInline(ctx context.Context, m map[string]any) error {
+	var sites []inlineSite
+	collectInline("", m, &sites)
+	if len(sites) == 0 {
+		return nil
+	}
+
+	fetched := make(map[inlineKey]any)
+	for _, s := range sites {
+		fetched[inlineKey{target: s.target, raw: s.key == ""}] = nil
+	}
+	if err := fetchInline(ctx, fetched); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, s := range sites {
+		v := fetched[inlineKey{target: s.target, raw: s.key == ""}]
+		if s.key == "" {
+			s.set(string(v.([]byte)))
+			continue
+		}
+		x, ok := configMap(v.(map[string]any)).get(s.key)
+		if !ok {
+			errs = append(errs, fmt.Errorf("error resolving inline reference at %q: no value at %q", s.path, s.key))
+			continue
+		}
+		s.set(x)
+	}
+	return errors.Join(errs...)
+}
+
+func collectInline(path string, v any, sites *[]inlineSite) {
+	add := func(p string, x any, set func(any)) {
+		s, ok := x.(string)
+		if !ok {
+			collectInline(p, x, sites)
+			return
+		}
+		ref, ok := strings.CutPrefix(s, InlinePrefix)
+		if !ok {
+			return
+		}
+		target, key, _ := strings.Cut(ref, "#")
+		*sites = append(*sites, inlineSite{path: p, target: target, key: key, set: set})
+	}
+	switch x := v.(type) {
+	case map[string]any:
+		for k := range x {
+			add(joinKey(path, k), x[k], func(v any) { x[k] = v })
+		}
+	case []any:
+		for i := range x {
+			add(joinKey(path, strconv.Itoa(i)), x[i], func(v any) { x[i] = v })
+		}
+	}
+}
+
+// fetchInline concurrently fetches the raw data or maps for the keys and
+// stores them in the map.
+func fetchInline(ctx context.Context, fetched map[inlineKey]any) error {
+	keys := make([]inlineKey, 0, len(fetched))
+	for k := range fetched {
+		keys = append(keys, k)
+	}
+	results := make([]any, len(keys))
+	errs := make([]error, len(keys))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			results[i], errs[i] = fetchInlineKey(ctx, keys[i])
+		}()
+	}
+	wg.Wait()
+	for i := range keys {
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("error resolving inline reference %q: %w", keys[i].target, errs[i])
+			continue
+		}
+		fetched[keys[i]] = results[i]
+	}
+	return errors.Join(errs...)
+}
+
+func fetchInlineKey(ctx context.Context, k inlineKey) (any, error) {
+	r := findResolver(k.target)
+	if r == nil {
+		return nil, ErrNoResolver
+	}
+	if !k.raw {
+		return r.Resolve(ctx, k.target)
+	}
+	rr, ok := r.(resolver.RawResolver)
+	if !ok {
+		return nil, fmt.Errorf("resolver %s does not support raw values", resolverName(r))
+	}
+	return rr.ResolveRaw(ctx, k.target)
+}
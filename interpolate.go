@@ -0,0 +1,217 @@
+package slimfig
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// interpolate replaces references in the string values of the configuration,
+// which take the following forms:
+//
+//	${env:VAR}            the value of environment variable VAR
+//	${key.path}           the value at the dotted key
+//	${key.path:-default}  the value at the dotted key, or else the default
+//
+// Defaults can be used with environment variables as well. If a string
+// consists of a single reference, it is replaced by a copy of the referenced
+// value, retaining its type. Otherwise, referenced values are converted to
+// strings. A reference can be escaped by doubling the dollar sign:
+// "$${not.a.ref}".
+//
+// References to missing keys without a default, as well as unterminated
+// references, are left as they are, so values like "echo ${HOME}" are kept.
+// Missing environment variables without a default are an error.
+func interpolate(m map[string]any) error {
+	in := &interpolator{root: m, done: make(map[string]bool)}
+	return in.walk("", m)
+}
+
+type interpolator struct {
+	root  map[string]any
+	stack []string
+	done  map[string]bool
+}
+
+func (in *interpolator) walk(path string, v any) error {
+	switch x := v.(type) {
+	case map[string]any:
+		for k := range x {
+			p := joinKey(path, k)
+			if _, err := in.resolve(p, x[k], func(v any) { x[k] = v }); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i := range x {
+			p := joinKey(path, strconv.Itoa(i))
+			if _, err := in.resolve(p, x[i], func(v any) { x[i] = v }); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve interpolates the value at the path and stores the result using set.
+func (in *interpolator) resolve(path string, v any, set func(any)) (any, error) {
+	s, ok := v.(string)
+	if !ok {
+		return v, in.walk(path, v)
+	}
+	if in.done[path] {
+		return v, nil
+	}
+	for i := range in.stack {
+		if in.stack[i] == path {
+			cycle := append(slices.Clone(in.stack[i:]), path)
+			return nil, fmt.Errorf("interpolation cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	in.stack = append(in.stack, path)
+	out, err := in.expand(s)
+	in.stack = in.stack[:len(in.stack)-1]
+	if err != nil {
+		return nil, fmt.Errorf("error interpolating %q: %w", path, err)
+	}
+	in.done[path] = true
+	set(out)
+	return out, nil
+}
+
+func (in *interpolator) expand(s string) (any, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	if expr, ok := singleReference(s); ok {
+		v, found, err := in.value(expr)
+		if err != nil || !found {
+			return s, err
+		}
+		return cloneValue(v), nil
+	}
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			sb.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		j := strings.Index(s[i:], "}")
+		if j < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		v, found, err := in.value(s[i+2 : i+j])
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			sb.WriteString(s[:i] + toString(v))
+		} else {
+			sb.WriteString(s[:i+j+1])
+		}
+		s = s[i+j+1:]
+	}
+}
+
+// singleReference returns the expression if the string consists of exactly
+// one reference.
+func singleReference(s string) (string, bool) {
+	if !strings.HasPrefix(s, "${") || !strings.HasSuffix(s, "}") {
+		return "", false
+	}
+	expr := s[2 : len(s)-1]
+	if strings.ContainsAny(expr, "${}") {
+		return "", false
+	}
+	return expr, true
+}
+
+// value returns the value of the expression. Returns false if it references a
+// missing key without a default.
+func (in *interpolator) value(expr string) (any, bool, error) {
+	expr, fallback, hasFallback := strings.Cut(expr, ":-")
+	if name, ok := strings.CutPrefix(expr, "env:"); ok {
+		if v, ok := os.LookupEnv(name); ok {
+			return v, true, nil
+		}
+		if hasFallback {
+			return fallback, true, nil
+		}
+		return nil, false, fmt.Errorf("environment variable %q is not set", name)
+	}
+	v, set, ok := in.lookup(expr)
+	if !ok {
+		return fallback, hasFallback, nil
+	}
+	v, err := in.resolve(expr, v, set)
+	return v, true, err
+}
+
+// lookup returns the value at the dotted key, along with a function to
+// replace it.
+func (in *interpolator) lookup(key string) (any, func(any), bool) {
+	var parent any = in.root
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		var v any
+		var set func(any)
+		switch x := parent.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = x[part]; !ok {
+				return nil, nil, false
+			}
+			set = func(v any) { x[part] = v }
+		case []any:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(x) {
+				return nil, nil, false
+			}
+			v = x[idx]
+			set = func(v any) { x[idx] = v }
+		default:
+			return nil, nil, false
+		}
+		if i == len(parts)-1 {
+			return v, set, true
+		}
+		parent = v
+	}
+	return nil, nil, false
+}
+
+// cloneValue copies maps and lists, so that interpolated values do not share
+// them with the values they reference.
+func cloneValue(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k := range x {
+			out[k] = cloneValue(x[k])
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i := range x {
+			out[i] = cloneValue(x[i])
+		}
+		return out
+	}
+	return v
+}
+
+func joinKey(path, k string) string {
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
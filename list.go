@@ -0,0 +1,85 @@
+package slimfig
+
+import (
+	"strings"
+)
+
+// DefaultListSeparator is the default separator for splitting strings into
+// lists.
+const DefaultListSeparator = ","
+
+var listSeparator = DefaultListSeparator
+
+// SetListSeparator sets the separator used by the slice getters to split
+// string values, for instance those set via environment variables:
+//
+//	XX_hosts='a.example.com, "b.example.com"'
+//
+// Items are trimmed of surrounding whitespace. Items can be enclosed in
+// double quotes to retain whitespace or the separator; within quotes, a
+// double quote is escaped by doubling it. An empty separator disables
+// splitting.
+func SetListSeparator(sep string) {
+	listSeparator = sep
+}
+
+// toList converts the value to a slice like toSlice, but splits strings into
+// items first.
+func toList[T any](a any, conv func(a any) (T, bool)) ([]T, bool) {
+	if s, ok := a.(string); ok && listSeparator != "" {
+		xs, ok := splitList(s, listSeparator)
+		if !ok {
+			return nil, false
+		}
+		a = xs
+	}
+	return toSlice(a, conv)
+}
+
+// splitList splits the string into items on the separator, honouring double
+// quotes. Returns false if a quote is not terminated or is followed by
+// anything other than the separator.
+func splitList(s, sep string) ([]string, bool) {
+	if strings.TrimSpace(s) == "" {
+		return nil, true
+	}
+	var out []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		var item string
+		if strings.HasPrefix(s, `"`) {
+			var sb strings.Builder
+			i := 1
+			for {
+				j := strings.IndexByte(s[i:], '"')
+				if j < 0 {
+					return nil, false
+				}
+				sb.WriteString(s[i : i+j])
+				i += j + 1
+				if !strings.HasPrefix(s[i:], `"`) {
+					break
+				}
+				sb.WriteByte('"')
+				i += 1
+			}
+			item = sb.String()
+			s = strings.TrimLeft(s[i:], " \t")
+			if s != "" && !strings.HasPrefix(s, sep) {
+				return nil, false
+			}
+		} else {
+			i := strings.Index(s, sep)
+			if i < 0 {
+				i = len(s)
+			}
+			item = strings.TrimSpace(s[:i])
+			s = s[i:]
+		}
+		out = append(out, item)
+		if s == "" {
+			return out, true
+		}
+		s = s[len(sep):]
+	}
+}
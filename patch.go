@@ -0,0 +1,419 @@
+package slimfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/HayoVanLoon/go-slimfig/resolver"
+)
+
+// PatchPrefix marks a reference as a patch, i.e.:
+//
+//	patch+json://ops.json
+//	patch+ops.json
+//
+// A "json://" scheme after the prefix is stripped, so the first two are the
+// same.
+//
+// Instead of being merged, a patch is applied to the configuration resolved
+// from the references before it. A JSON array is applied as a JSON Patch
+// (RFC 6902), a JSON object as a JSON Merge Patch (RFC 7386). A failing
+// JSON Patch operation, including a "test" operation, fails the load.
+//
+// The patch is fetched as raw data, so its resolver must implement
+// resolver.RawResolver. Patches cannot have a path or key, and cannot be
+// included.
+const PatchPrefix = "patch+"
+
+// patchScheme is stripped from the target of patch references.
+const patchScheme = "json://"
+
+// PatchSuffix marks references to files ending with it as patches, without
+// the need for PatchPrefix.
+const PatchSuffix = ".patch.json"
+
+// A patch modifies a configuration map.
+type patch interface {
+	apply(cfg map[string]any) (map[string]any, error)
+	// size returns the number of operations or top-level keys.
+	size() int
+}
+
+// resolvePatch fetches and parses the patch for the reference.
+func resolvePatch(ctx context.Context, r resolver.Resolver, ref reference, res *result, rep *ReferenceReport) error {
+	if ref.path != "" || ref.mount != "" {
+		return errors.New("patches cannot have a path or key")
+	}
+	rr, ok := r.(resolver.RawResolver)
+	if !ok {
+		return fmt.Errorf("resolver %T does not support patches", r)
+	}
+	data, err := rr.ResolveRaw(ctx, ref.target)
+	if ref.optional && errors.Is(err, resolver.ErrNotFound) {
+		rep.Skipped = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p, err := parsePatch(data)
+	if err != nil {
+		return err
+	}
+	rep.Size = len(data)
+	rep.Keys = p.size()
+	res.patch = p
+	return nil
+}
+
+func parsePatch(data []byte) (patch, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var ops jsonPatch
+		if err := json.Unmarshal(data, &ops); err != nil {
+			return nil, fmt.Errorf("invalid JSON Patch: %w", err)
+		}
+		for i, op := range ops {
+			if err := op.validate(); err != nil {
+				return nil, fmt.Errorf("invalid JSON Patch operation %d: %w", i, err)
+			}
+		}
+		return ops, nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid JSON Merge Patch: %w", err)
+	}
+	return mergePatch(m), nil
+}
+
+// A mergePatch is a JSON Merge Patch (RFC 7386).
+type mergePatch map[string]any
+
+func (p mergePatch) size() int {
+	return len(p)
+}
+
+func (p mergePatch) apply(cfg map[string]any) (map[string]any, error) {
+	return applyMergePatch(cfg, p), nil
+}
+
+func applyMergePatch(target any, p map[string]any) map[string]any {
+	m, ok := toMap(target, toAny)
+	if !ok || m == nil {
+		m = map[string]any{}
+	}
+	for k, v := range p {
+		switch v := v.(type) {
+		case nil:
+			delete(m, k)
+		case map[string]any:
+			m[k] = applyMergePatch(m[k], v)
+		default:
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// A jsonPatch is a JSON Patch (RFC 6902).
+type jsonPatch []patchOperation
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (p jsonPatch) size() int {
+	return len(p)
+}
+
+func (p jsonPatch) apply(cfg map[string]any) (map[string]any, error) {
+	var doc any = cfg
+	for i, op := range p {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			return nil, fmt.Errorf("JSON Patch operation %d (%s %s): %w", i, op.Op, *op.Path, err)
+		}
+	}
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return nil, errors.New("JSON Patch result is not a map")
+	}
+	return m, nil
+}
+
+func (op patchOperation) validate() error {
+	if op.Path == nil {
+		return errors.New("missing path")
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("missing value for %q", op.Op)
+		}
+	case "move", "copy":
+		if op.From == nil {
+			return fmt.Errorf("missing from for %q", op.Op)
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	return nil
+}
+
+func (op patchOperation) value() (any, error) {
+	var v any
+	if err := json.Unmarshal(op.Value, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (op patchOperation) apply(doc any) (any, error) {
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, v)
+	case "remove":
+		doc, _, err := pointerRemove(doc, path)
+		return doc, err
+	case "replace":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if doc, _, err = pointerRemove(doc, path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, v)
+	case "move":
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+			return nil, errors.New("cannot move a value into itself")
+		}
+		doc, v, err := pointerRemove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, v)
+	case "copy":
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, deepCopy(v))
+	case "test":
+		expected, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(expected, actual) {
+			return nil, fmt.Errorf("test failed: expected %s, got %s", op.Value, mustMarshal(actual))
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// parsePointer parses a JSON Pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("invalid JSON Pointer %q", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func pointerGet(doc any, path []string) (any, error) {
+	for _, t := range path {
+		switch v := normalise(doc).(type) {
+		case map[string]any:
+			x, ok := v[t]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found", t)
+			}
+			doc = x
+		case []any:
+			i, err := arrayIndex(t, len(v)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("cannot resolve %q in a %T", t, doc)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds the value at the path and returns the updated document.
+func pointerAdd(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	t, rest := path[0], path[1:]
+	switch v := normalise(doc).(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			v[t] = value
+			return v, nil
+		}
+		x, ok := v[t]
+		if !ok {
+			return nil, fmt.Errorf("key %q not found", t)
+		}
+		x, err := pointerAdd(x, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		v[t] = x
+		return v, nil
+	case []any:
+		if len(rest) == 0 {
+			i := len(v)
+			if t != "-" {
+				var err error
+				if i, err = arrayIndex(t, len(v)); err != nil {
+					return nil, err
+				}
+			}
+			return append(v[:i:i], append([]any{value}, v[i:]...)...), nil
+		}
+		i, err := arrayIndex(t, len(v)-1)
+		if err != nil {
+			return nil, err
+		}
+		if v[i], err = pointerAdd(v[i], rest, value); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("cannot add %q to a %T", t, doc)
+}
+
+// pointerRemove removes the value at the path and returns the updated
+// document and the removed value.
+func pointerRemove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	t, rest := path[0], path[1:]
+	switch v := normalise(doc).(type) {
+	case map[string]any:
+		x, ok := v[t]
+		if !ok {
+			return nil, nil, fmt.Errorf("key %q not found", t)
+		}
+		if len(rest) == 0 {
+			delete(v, t)
+			return v, x, nil
+		}
+		x, removed, err := pointerRemove(x, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		v[t] = x
+		return v, removed, nil
+	case []any:
+		i, err := arrayIndex(t, len(v)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			return append(v[:i:i], v[i+1:]...), v[i], nil
+		}
+		x, removed, err := pointerRemove(v[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		v[i] = x
+		return v, removed, nil
+	}
+	return nil, nil, fmt.Errorf("cannot remove %q from a %T", t, doc)
+}
+
+// arrayIndex parses the token as an array index no greater than max.
+func arrayIndex(t string, max int) (int, error) {
+	if t == "" || (len(t) > 1 && t[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+	i, err := strconv.Atoi(t)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+// normalise converts maps and lists of other types to map[string]any and
+// []any, so they can be modified in place.
+func normalise(v any) any {
+	switch v.(type) {
+	case map[string]any, []any:
+		return v
+	}
+	if m, ok := toMap(v, toAny); ok && m != nil {
+		return m
+	}
+	if xs, ok := toSlice(v, toAny); ok && xs != nil {
+		return xs
+	}
+	return v
+}
+
+func deepCopy(v any) any {
+	var out any
+	_ = json.Unmarshal(mustMarshal(v), &out)
+	return out
+}
+
+// jsonEqual compares values by their JSON representation, so numeric types
+// and list and map types need not match exactly.
+func jsonEqual(a, b any) bool {
+	return reflect.DeepEqual(deepCopy(a), deepCopy(b))
+}
+
+func mustMarshal(v any) []byte {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return []byte(fmt.Sprint(v))
+	}
+	return bs
+}
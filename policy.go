@@ -0,0 +1,261 @@
+package slimfig
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrEnvNotAllowed is wrapped by an EnvError for environment variables that
+// override a key the EnvPolicy does not allow.
+var ErrEnvNotAllowed = errors.New("not allowed")
+
+// ErrEnvUnknownKey is wrapped by an EnvError for environment variables that
+// override an unknown key in strict mode (see EnvPolicy.Strict).
+var ErrEnvUnknownKey = errors.New("unknown key")
+
+// An EnvError is the error for an environment variable that could not be
+// applied.
+type EnvError struct {
+	// Variable is the name of the environment variable.
+	Variable string
+	// Key is the dotted key the variable maps to.
+	Key string
+	// Err is the underlying error.
+	Err error
+	// Suggestions holds known keys similar to Key, for unknown keys.
+	Suggestions []string
+}
+
+func (e *EnvError) Error() string {
+	msg := fmt.Sprintf("environment variable %s cannot override %q: %v", e.Variable, e.Key, e.Err)
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf(" (did you mean %q?)", strings.Join(e.Suggestions, `", "`))
+	}
+	return msg
+}
+
+func (e *EnvError) Unwrap() error {
+	return e.Err
+}
+
+// An EnvPolicy restricts which keys can be overridden by environment
+// variables. Patterns are dotted keys in which every part can be a glob
+// pattern (see path.Match), like "auth.*" or "db.*_url". A pattern also
+// matches all keys below the keys it matches, so "auth" matches
+// "auth.issuer".
+type EnvPolicy struct {
+	// Allow holds the patterns of keys that may be overridden. When empty,
+	// all keys may be overridden, unless denied. Keys of which only some
+	// descendants are allowed may not be overridden, so allowing
+	// "auth.issuer" does not allow "auth".
+	Allow []string
+	// Deny holds the patterns of keys that may not be overridden. Deny takes
+	// precedence over Allow. Overriding a key is also denied when it would
+	// change denied keys below it, so denying "auth.issuer" denies setting
+	// "auth" to a map with an issuer, or to a string.
+	Deny []string
+	// Strict only allows overriding known keys, catching typos like
+	// XX_servce__host. Known keys are those declared with Define, plus those
+	// loaded from the configuration scheme, or the keys matching the Schema
+	// patterns when set. Items can be appended to known lists.
+	Strict bool
+	// Schema holds the patterns of known keys for strict mode.
+	Schema []string
+	// Reject makes Load fail when environment variables override keys that
+	// are not allowed or unknown. Otherwise, these variables are skipped and
+	// listed in the LoadReport.
+	Reject bool
+}
+
+var envPolicy *EnvPolicy
+
+// SetEnvPolicy sets the policy for overriding keys with environment
+// variables. Passing nil allows all overrides, which is the default. Must be
+// called before Load.
+func SetEnvPolicy(p *EnvPolicy) {
+	envPolicy = p
+}
+
+// allows returns true if the policy allows overriding the key.
+func (p *EnvPolicy) allows(key string) bool {
+	if p == nil {
+		return true
+	}
+	for _, pattern := range p.Deny {
+		if matchKey(pattern, key) {
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range p.Allow {
+		if matchKey(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns an error if the policy does not allow overriding the key parts
+// in the configuration with the value.
+func (p *EnvPolicy) check(cfg configMap, parts []string, v any) error {
+	key := strings.Join(parts, ".")
+	if !p.allows(key) {
+		return ErrEnvNotAllowed
+	}
+	old, _ := cfg.get2(parts)
+	for _, k := range changedKeys(key, old, v) {
+		if !p.allows(k) {
+			return ErrEnvNotAllowed
+		}
+	}
+	if p == nil || !p.Strict || p.knows(cfg, parts) {
+		return nil
+	}
+	return ErrEnvUnknownKey
+}
+
+// changedKeys returns the keys below the key that change when its old value
+// is overridden by the value. Maps are merged into maps, other values replace
+// the old value and all keys below it.
+func changedKeys(key string, old, v any) []string {
+	vm, ok := toMap(v, toAny)
+	if !ok {
+		return dottedKeys(anyMap(old), key)
+	}
+	om, ok := toMap(old, toAny)
+	if !ok {
+		return append(dottedKeys(anyMap(old), key), dottedKeys(vm, key)...)
+	}
+	var out []string
+	for k, x := range vm {
+		k2 := joinKey(key, k)
+		out = append(out, k2)
+		out = append(out, changedKeys(k2, om[k], x)...)
+	}
+	return out
+}
+
+// anyMap returns the value as a map, or nil if it is not one.
+func anyMap(v any) map[string]any {
+	m, _ := toMap(v, toAny)
+	return m
+}
+
+// knows returns true if the key parts are known in strict mode.
+func (p *EnvPolicy) knows(cfg configMap, parts []string) bool {
+	key := strings.Join(parts, ".")
+	if defined(key) {
+		return true
+	}
+	if len(p.Schema) > 0 {
+		for _, pattern := range p.Schema {
+			if matchKey(pattern, key) {
+				return true
+			}
+		}
+		return false
+	}
+	if _, ok := cfg.get2(parts); ok {
+		return true
+	}
+	if len(parts) < 2 {
+		return false
+	}
+	parent, _ := cfg.get2(parts[:len(parts)-1])
+	xs, ok := toSlice(parent, toAny)
+	if !ok {
+		return false
+	}
+	_, ok = listIndex(parts[len(parts)-1], len(xs))
+	return ok
+}
+
+// suggestions returns the known keys closest to the key, if any are close
+// enough to be a likely typo.
+func (p *EnvPolicy) suggestions(cfg configMap, key string) []string {
+	candidates := p.Schema
+	if len(candidates) == 0 {
+		candidates = dottedKeys(cfg, "")
+	}
+	for _, d := range Definitions() {
+		candidates = append(candidates, d.Key)
+	}
+	best := len(key)/3 + 1
+	var out []string
+	for _, c := range candidates {
+		d := editDistance(strings.ToLower(key), strings.ToLower(c))
+		switch {
+		case d < best:
+			best = d
+			out = []string{c}
+		case d == best:
+			out = append(out, c)
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// dottedKeys returns the dotted keys of all maps and values in the map.
+func dottedKeys(m map[string]any, prefix string) []string {
+	var out []string
+	for k, v := range m {
+		k = joinKey(prefix, k)
+		out = append(out, k)
+		if m2, ok := toMap(v, toAny); ok {
+			out = append(out, dottedKeys(m2, k)...)
+		}
+	}
+	return out
+}
+
+// editDistance returns the Levenshtein distance between the strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i += 1 {
+		curr[0] = i
+		for j := 1; j <= len(b); j += 1 {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// matchKey returns true if the pattern matches the key or one of its
+// ancestors. Invalid patterns match nothing.
+func matchKey(pattern, key string) bool {
+	ps := strings.Split(pattern, ".")
+	ks := strings.Split(key, ".")
+	if len(ps) > len(ks) {
+		return false
+	}
+	for i := range ps {
+		if ok, _ := path.Match(ps[i], ks[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// envError joins the errors into one.
+func envError(errs []*EnvError) error {
+	out := make([]error, len(errs))
+	for i := range errs {
+		out[i] = errs[i]
+	}
+	return errors.Join(out...)
+}
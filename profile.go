@@ -0,0 +1,70 @@
+package slimfig
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvProfileSuffix is the suffix added to the prefix to build the profile
+// environment variable, i.e.:
+//
+//	profiles := os.Getenv(prefix + "_" + EnvProfileSuffix)
+//
+// Every reference is followed by an optional sibling for each profile, in the
+// order given. For instance, with "XX_PROFILE=prod,eu", "config.yaml" expands
+// into "config.yaml", "?config.prod.yaml" and "?config.eu.yaml".
+const EnvProfileSuffix = "PROFILE"
+
+func profiles(prefix string) []string {
+	if prefix == "" {
+		return nil
+	}
+	var out []string
+	for _, p := range strings.Split(os.Getenv(prefix+"_"+EnvProfileSuffix), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// expandProfiles expands each reference into itself, followed by an optional
+// sibling reference for every profile. References without a file extension
+// are not expanded.
+func expandProfiles(references []string, profiles []string) []string {
+	if len(profiles) == 0 {
+		return references
+	}
+	var out []string
+	for _, s := range references {
+		out = append(out, s)
+		ref := parseReference(s)
+		for _, p := range profiles {
+			if target, ok := profileTarget(ref.target, p); ok {
+				sibling := ref
+				sibling.target = target
+				sibling.optional = true
+				out = append(out, sibling.String())
+			}
+		}
+	}
+	return out
+}
+
+// profileTarget inserts the profile before the extension of the target, i.e.
+// "dir/config.yaml" becomes "dir/config.prod.yaml". Only the part before any
+// "#" is considered, so resolver parameters like "#as=auth.key" are kept as
+// they are. Returns false if the target has no extension.
+func profileTarget(target, profile string) (string, bool) {
+	name, fragment, hasFragment := strings.Cut(target, "#")
+	slash := strings.LastIndex(name, "/")
+	dot := strings.LastIndex(name, ".")
+	if dot <= slash+1 {
+		return "", false
+	}
+	out := name[:dot] + "." + profile + name[dot:]
+	if hasFragment {
+		out += "#" + fragment
+	}
+	return out, true
+}
@@ -0,0 +1,119 @@
+package slimfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A reference is a parsed entry of the configuration scheme. Its general form
+// is:
+//
+//	[?][patch+]target[#[path][@key]]
+//
+// The target is passed on to the resolver. The optional path selects a subtree
+// of the resolved map, while the optional key mounts the (selected) result
+// under that key, i.e. "db.json#@db". Both path and key are dotted keys. As
+// they are only recognised in the fragment, an "@" in the target, like in
+// "config@v2.json", is left alone. Fragments containing a "=", like
+// "#as=key", are considered resolver parameters and remain part of the
+// target.
+//
+// A leading "?" marks the reference as optional; it is skipped when the
+// resolver reports it could not be found. A "patch+" prefix, or a target
+// ending in ".patch.json", marks the reference as a patch (see PatchPrefix).
+type reference struct {
+	raw      string
+	target   string
+	path     string
+	mount    string
+	optional bool
+	patch    bool
+}
+
+func parseReference(s string) reference {
+	s = strings.TrimSpace(s)
+	ref := reference{raw: s, target: s}
+	if t, ok := strings.CutPrefix(ref.target, "?"); ok {
+		ref.optional = true
+		ref.target = t
+	}
+	if t, ok := strings.CutPrefix(ref.target, PatchPrefix); ok {
+		ref.patch = true
+		ref.target = strings.TrimPrefix(t, patchScheme)
+	}
+	if t, fragment, ok := strings.Cut(ref.target, "#"); ok {
+		path, mount, hasMount := strings.Cut(fragment, "@")
+		if (path == "" || isKey(path)) && (!hasMount || isKey(mount)) && (path != "" || hasMount) {
+			ref.path = path
+			ref.mount = mount
+			ref.target = t
+		}
+	}
+	if strings.HasSuffix(ref.target, PatchSuffix) {
+		ref.patch = true
+	}
+	return ref
+}
+
+// String returns the reference in scheme notation.
+func (r reference) String() string {
+	var sb strings.Builder
+	if r.optional {
+		sb.WriteString("?")
+	}
+	if r.patch && !strings.HasSuffix(r.target, PatchSuffix) {
+		sb.WriteString(PatchPrefix)
+	}
+	sb.WriteString(r.target)
+	if r.path != "" || r.mount != "" {
+		sb.WriteString("#" + r.path)
+	}
+	if r.mount != "" {
+		sb.WriteString("@" + r.mount)
+	}
+	return sb.String()
+}
+
+// isKey returns true if s can be used as a dotted key.
+func isKey(s string) bool {
+	if s == "" || strings.ContainsAny(s, "/:#@=&?") {
+		return false
+	}
+	for _, part := range strings.Split(s, ".") {
+		if part == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// apply applies the subtree selection and mounting to the resolved map.
+func (r reference) apply(m map[string]any) (map[string]any, error) {
+	var v any = m
+	if r.path != "" {
+		var ok bool
+		if v, ok = configMap(m).get(r.path); !ok {
+			return nil, fmt.Errorf("no value at %q", r.path)
+		}
+	}
+	if r.mount != "" {
+		return nest(strings.Split(r.mount, "."), v), nil
+	}
+	out, ok := v.(map[string]any)
+	if !ok {
+		if out, ok = toMap(v, toAny); !ok {
+			return nil, fmt.Errorf("value at %q is not a map", r.path)
+		}
+	}
+	return out, nil
+}
+
+// nest returns a map with the value at the location described by the key
+// parts.
+func nest(parts []string, v any) map[string]any {
+	m := map[string]any{parts[len(parts)-1]: v}
+	for i := len(parts) - 2; i >= 0; i -= 1 {
+		m = map[string]any{parts[i]: m}
+	}
+	return m
+}
@@ -0,0 +1,203 @@
+package slimfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrMissingKey is returned by Load, wrapped, when required keys (see
+// Required) are missing from the configuration.
+var ErrMissingKey = errors.New("missing required key")
+
+// A KeyType is a type that can be used for keys declared with Define.
+type KeyType interface {
+	string | int | float64 | bool | time.Duration |
+		[]string | []int | []float64 | []bool |
+		map[string]string | map[string]int | map[string]float64 | map[string]bool
+}
+
+// A Key is a configuration key declared with Define.
+type Key[T KeyType] struct {
+	def Definition
+	val T
+}
+
+// A Definition describes a declared key, for instance for generating
+// documentation.
+type Definition struct {
+	// Key is the dotted configuration key.
+	Key string
+	// Type is the Go type of the key's values.
+	Type string
+	// Default is the default value. It is nil for sensitive keys.
+	Default any
+	// Description describes the key.
+	Description string
+	// Env is the name of the environment variable that overrides the key,
+	// if any.
+	Env string
+	// Sensitive is true for keys holding secrets.
+	Sensitive bool
+	// Required is true for keys that must be present after loading.
+	Required bool
+}
+
+// A KeyOption modifies a key definition.
+type KeyOption func(*Definition)
+
+// Env sets the name of an environment variable that overrides the key. Unlike
+// the variables starting with the prefix passed to Load, the name is used as
+// is and the variable is applied regardless of the EnvPolicy.
+func Env(name string) KeyOption {
+	return func(d *Definition) {
+		d.Env = name
+	}
+}
+
+// Sensitive marks the key as holding a secret. Its default is left out of its
+// definition and its value is redacted when formatting the key.
+func Sensitive() KeyOption {
+	return func(d *Definition) {
+		d.Sensitive = true
+	}
+}
+
+// Required makes Load fail when the key is missing from the configuration.
+func Required() KeyOption {
+	return func(d *Definition) {
+		d.Required = true
+	}
+}
+
+var registry = map[string]*Definition{}
+
+// Define declares a configuration key with its type, default and
+// description, so that the fallback for a key is defined in one place:
+//
+//	var Timeout = slimfig.Define[time.Duration]("timeout", 30*time.Second, "request timeout")
+//
+//	ctx, cancel := context.WithTimeout(ctx, Timeout.Get())
+//
+// Define is meant to be called during package initialisation, before Load.
+// Declaring a key twice panics.
+func Define[T KeyType](key string, def T, description string, opts ...KeyOption) *Key[T] {
+	if _, ok := registry[key]; ok {
+		panic(fmt.Sprintf("slimfig: key %q defined twice", key))
+	}
+	d := Definition{
+		Key:         key,
+		Type:        fmt.Sprintf("%T", def),
+		Default:     def,
+		Description: description,
+	}
+	for _, o := range opts {
+		o(&d)
+	}
+	if d.Sensitive {
+		d.Default = nil
+	}
+	registry[key] = &d
+	return &Key[T]{def: d, val: def}
+}
+
+// Get looks up the key's value, falling back to its default.
+func (k *Key[T]) Get() T {
+	var out any
+	switch def := any(k.val).(type) {
+	case string:
+		out = String(k.def.Key, def)
+	case int:
+		out = Int(k.def.Key, def)
+	case float64:
+		out = Float(k.def.Key, def)
+	case bool:
+		out = Bool(k.def.Key, def)
+	case time.Duration:
+		out = Duration(k.def.Key, def)
+	case []string:
+		out = StringSlice(k.def.Key, def)
+	case []int:
+		out = IntSlice(k.def.Key, def)
+	case []float64:
+		out = FloatSlice(k.def.Key, def)
+	case []bool:
+		out = BoolSlice(k.def.Key, def)
+	case map[string]string:
+		out = StringMap(k.def.Key, def)
+	case map[string]int:
+		out = IntMap(k.def.Key, def)
+	case map[string]float64:
+		out = FloatMap(k.def.Key, def)
+	case map[string]bool:
+		out = BoolMap(k.def.Key, def)
+	}
+	return out.(T)
+}
+
+// Definition returns the key's definition.
+func (k *Key[T]) Definition() Definition {
+	return k.def
+}
+
+// String returns the key and its value, with the value redacted for sensitive
+// keys.
+func (k *Key[T]) String() string {
+	if k.def.Sensitive {
+		return k.def.Key + "=REDACTED"
+	}
+	return fmt.Sprintf("%s=%v", k.def.Key, k.Get())
+}
+
+// Definitions returns the definitions of all declared keys, ordered by key.
+func Definitions() []Definition {
+	out := make([]Definition, 0, len(registry))
+	for _, d := range registry {
+		out = append(out, *d)
+	}
+	slices.SortFunc(out, func(a, b Definition) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return out
+}
+
+// defined returns true if the dotted key, or one of its ancestors, has been
+// declared.
+func defined(key string) bool {
+	for _, d := range registry {
+		if matchKey(d.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDefinedEnv applies the environment variables of the declared keys.
+func applyDefinedEnv() {
+	for _, d := range Definitions() {
+		if d.Env == "" {
+			continue
+		}
+		if v, ok := os.LookupEnv(d.Env); ok {
+			addEnv(&config, strings.Split(d.Key, "."), v)
+		}
+	}
+}
+
+// checkRequired returns an error listing the required keys missing from the
+// configuration.
+func checkRequired() error {
+	var missing []string
+	for _, d := range Definitions() {
+		if _, ok := config.get(d.Key); d.Required && !ok {
+			missing = append(missing, d.Key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrMissingKey, strings.Join(missing, ", "))
+}
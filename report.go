@@ -0,0 +1,146 @@
+package slimfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/HayoVanLoon/go-slimfig/resolver"
+)
+
+// ErrNoResolver is wrapped by a ReferenceError when none of the resolvers
+// matches the reference.
+var ErrNoResolver = errors.New("no resolver")
+
+// A ReferenceError is the error for a single reference that could not be
+// resolved.
+type ReferenceError struct {
+	// Reference is the reference as it appears in the scheme.
+	Reference string
+	// Resolver is the (type) name of the matching resolver. It is empty
+	// when no resolver matched.
+	Resolver string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ReferenceError) Error() string {
+	if errors.Is(e.Err, ErrNoResolver) {
+		return fmt.Sprintf("no resolver for %q", e.Reference)
+	}
+	return fmt.Sprintf("error resolving %q: %v", e.Reference, e.Err)
+}
+
+func (e *ReferenceError) Unwrap() error {
+	return e.Err
+}
+
+// A LoadError lists the errors for all references that could not be resolved.
+// Like errors created by errors.Join, it can be inspected with errors.Is and
+// errors.As.
+type LoadError struct {
+	Errors []*ReferenceError
+}
+
+func (e *LoadError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i := range e.Errors {
+		msgs[i] = e.Errors[i].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *LoadError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i := range e.Errors {
+		errs[i] = e.Errors[i]
+	}
+	return errs
+}
+
+// A LoadReport describes the resolution of a configuration scheme.
+type LoadReport struct {
+	// References holds a report for each reference, in scheme order.
+	References []ReferenceReport
+	// SkippedEnv holds the errors for environment variables that were not
+	// applied, for instance because the EnvPolicy does not allow them.
+	SkippedEnv []*EnvError
+}
+
+// A ReferenceReport describes the resolution of a single reference.
+type ReferenceReport struct {
+	// Reference is the reference as it appears in the scheme.
+	Reference string
+	// Resolver is the (type) name of the matching resolver. It is empty
+	// when no resolver matched.
+	Resolver string
+	// Duration is the time it took to resolve the reference.
+	Duration time.Duration
+	// Size is the size in bytes of the JSON representation of the
+	// contributed map.
+	Size int
+	// Keys is the number of (leaf) keys contributed.
+	Keys int
+	// Skipped is true for optional references that could not be found.
+	Skipped bool
+	// Err holds the error if the reference could not be resolved.
+	Err error
+}
+
+var lastReport *LoadReport
+
+// Report returns the report of the last call to Load, or nil if it has not
+// been called.
+func Report() *LoadReport {
+	return lastReport
+}
+
+func (r *LoadReport) err() error {
+	var errs []*ReferenceError
+	for _, ref := range r.References {
+		var err *ReferenceError
+		if errors.As(ref.Err, &err) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &LoadError{Errors: errs}
+}
+
+func resolverName(r resolver.Resolver) string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("%T", r)
+}
+
+// size returns the size of the JSON representation of the map, or zero if it
+// cannot be serialised.
+func size(m map[string]any) int {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// countKeys returns the number of leaf keys in the map.
+func countKeys(m map[string]any) int {
+	n := 0
+	for _, v := range m {
+		m2, ok := v.(map[string]any)
+		if !ok {
+			m2, ok = toMap(v, toAny)
+		}
+		if ok && len(m2) > 0 {
+			n += countKeys(m2)
+		} else {
+			n += 1
+		}
+	}
+	return n
+}
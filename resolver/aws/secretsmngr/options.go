@@ -0,0 +1,63 @@
+package secretsmngr
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// An Option modifies the client created by Resolver and JSONResolver.
+type Option func(*options)
+
+type options struct {
+	baseEndpoint string
+	region       string
+	credentials  aws.CredentialsProvider
+	httpClient   aws.HTTPClient
+}
+
+func (o options) loadOptions() []func(*config.LoadOptions) error {
+	var opts []func(*config.LoadOptions) error
+	if o.baseEndpoint != "" {
+		opts = append(opts, config.WithBaseEndpoint(o.baseEndpoint))
+	}
+	if o.region != "" {
+		opts = append(opts, config.WithRegion(o.region))
+	}
+	if o.credentials != nil {
+		opts = append(opts, config.WithCredentialsProvider(o.credentials))
+	}
+	return opts
+}
+
+// WithBaseEndpoint sets the endpoint the client connects to, i.e.
+// "http://localhost:4566" for LocalStack.
+func WithBaseEndpoint(url string) Option {
+	return func(o *options) {
+		o.baseEndpoint = url
+	}
+}
+
+// WithRegion sets the region, overriding the one from the environment or
+// shared configuration.
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+
+// WithCredentialsProvider sets the credentials provider, overriding the default
+// credential chain. Use credentials.NewStaticCredentialsProvider for local
+// stand-ins that accept dummy credentials.
+func WithCredentialsProvider(p aws.CredentialsProvider) Option {
+	return func(o *options) {
+		o.credentials = p
+	}
+}
+
+// WithHTTPClient sets the HTTP client. Defaults to the client configured by
+// the SDK.
+func WithHTTPClient(c aws.HTTPClient) Option {
+	return func(o *options) {
+		o.httpClient = c
+	}
+}
@@ -3,8 +3,8 @@ package secretsmngr
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"os"
 	"strings"
 
@@ -14,43 +14,58 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 )
 
-var _ res.Resolver = *new(resolver)
+var _ res.RawResolver = *new(resolver)
 
 type resolver struct {
 	base.Resolver
 }
 
 func (r resolver) Matches(reference string) bool {
-	return validName(reference) != ""
+	return validName(base.StripFragment(reference)) != ""
 }
 
 // JSONResolver returns a Secret Manager resolver for secrets containing JSON
 // objects.
-func JSONResolver(ctx context.Context) (res.Resolver, error) {
-	return Resolver(ctx, json.Unmarshal)
+func JSONResolver(ctx context.Context, opts ...Option) (res.Resolver, error) {
+	return Resolver(ctx, json.Unmarshal, opts...)
 }
 
 // Resolver returns a Secrets Manager resolver for secrets that can be
-// unmarshalled into maps using the provided function.
-func Resolver(ctx context.Context, unmarshal base.Unmarshaller) (res.Resolver, error) {
-	var opts []func(*config.LoadOptions) error
+// unmarshalled into maps using the provided function. The client is created
+// from the default configuration, which can be modified with options.
+func Resolver(ctx context.Context, unmarshal base.Unmarshaller, opts ...Option) (res.Resolver, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var loadOpts []func(*config.LoadOptions) error
 	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
-		opts = append(opts, config.WithSharedConfigProfile(profile))
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(profile))
 	}
-	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	loadOpts = append(loadOpts, o.loadOptions()...)
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("could not load default config: %w", err)
 	}
-	cfg.HTTPClient = http.DefaultClient
+	if o.httpClient != nil {
+		cfg.HTTPClient = o.httpClient
+	}
 	c := secretsmanager.NewFromConfig(cfg)
 	return WithClient(c, unmarshal), nil
 }
 
+// A Client retrieves secret values. It is implemented by
+// *secretsmanager.Client, but can be replaced by a fake in tests.
+type Client interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
 // WithClient returns a Secrets Manager resolver with the given client and
 // unmarshaller.
-func WithClient(c *secretsmanager.Client, unmarshal base.Unmarshaller) res.Resolver {
+func WithClient(c Client, unmarshal base.Unmarshaller) res.Resolver {
 	return resolver{
 		Resolver: base.Resolver{
 			Fetch:     fetchFn(c),
@@ -59,12 +74,16 @@ func WithClient(c *secretsmanager.Client, unmarshal base.Unmarshaller) res.Resol
 	}
 }
 
-func fetchFn(c *secretsmanager.Client) base.Fetcher {
+func fetchFn(c Client) base.Fetcher {
 	return func(ctx context.Context, reference string) ([]byte, error) {
 		req := &secretsmanager.GetSecretValueInput{
 			SecretId: aws.String(validName(reference)),
 		}
 		resp, err := c.GetSecretValue(ctx, req)
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%w: %w", res.ErrNotFound, err)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,122 @@
+package secretsmngr_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/require"
+
+	res "github.com/HayoVanLoon/go-slimfig/resolver"
+	"github.com/HayoVanLoon/go-slimfig/resolver/aws/secretsmngr"
+	"github.com/HayoVanLoon/go-slimfig/resolver/fake"
+)
+
+func TestResolver_Matches(t *testing.T) {
+	r := secretsmngr.WithClient(&fake.AWSSecretsManager{}, json.Unmarshal)
+	tests := []struct {
+		reference string
+		want      bool
+	}{
+		{"aws-secretsmanager://my-secret", true},
+		{"aws-secretsmanager://", false},
+		{"gcp-secretmanager://projects/1/secrets/foo", false},
+		{"my-secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reference, func(t *testing.T) {
+			require.Equal(t, tt.want, r.Matches(tt.reference))
+		})
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	type want struct {
+		value map[string]any
+		calls []string
+		err   require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name      string
+		client    *fake.AWSSecretsManager
+		reference string
+		want      want
+	}{
+		{
+			"happy",
+			&fake.AWSSecretsManager{
+				Secrets: map[string]string{"my-secret": `{"a": {"b": "c"}}`},
+			},
+			"aws-secretsmanager://my-secret",
+			want{
+				map[string]any{"a": map[string]any{"b": "c"}},
+				[]string{"my-secret"},
+				require.NoError,
+			},
+		},
+		{
+			"not found",
+			&fake.AWSSecretsManager{},
+			"aws-secretsmanager://my-secret",
+			want{nil, []string{"my-secret"}, errorIs(res.ErrNotFound)},
+		},
+		{
+			"client error",
+			&fake.AWSSecretsManager{Err: fmt.Errorf("oh noes")},
+			"aws-secretsmanager://my-secret",
+			want{nil, []string{"my-secret"}, require.Error},
+		},
+		{
+			"invalid json",
+			&fake.AWSSecretsManager{
+				Secrets: map[string]string{"my-secret": "not json"},
+			},
+			"aws-secretsmanager://my-secret",
+			want{nil, []string{"my-secret"}, require.Error},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := secretsmngr.WithClient(tt.client, json.Unmarshal)
+			actual, err := r.Resolve(context.Background(), tt.reference)
+			tt.want.err(t, err)
+			require.Equal(t, tt.want.value, actual)
+			require.Equal(t, tt.want.calls, tt.client.Calls())
+		})
+	}
+}
+
+func TestResolver_options(t *testing.T) {
+	var target string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.Header.Get("X-Amz-Target")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_, _ = w.Write([]byte(`{"Name": "my-secret", "SecretString": "{\"a\": 1}"}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	r, err := secretsmngr.JSONResolver(
+		ctx,
+		secretsmngr.WithBaseEndpoint(srv.URL),
+		secretsmngr.WithRegion("eu-west-1"),
+		secretsmngr.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("id", "secret", "")),
+		secretsmngr.WithHTTPClient(srv.Client()),
+	)
+	require.NoError(t, err)
+
+	actual, err := r.Resolve(ctx, "aws-secretsmanager://my-secret")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": float64(1)}, actual)
+	require.Equal(t, "secretsmanager.GetSecretValue", target)
+}
+
+func errorIs(target error) require.ErrorAssertionFunc {
+	return func(t require.TestingT, err error, _ ...interface{}) {
+		require.ErrorIs(t, err, target)
+	}
+}
@@ -3,6 +3,8 @@ package base
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strings"
 )
 
 type (
@@ -14,21 +16,44 @@ type (
 	Unmarshaller func([]byte, any) error
 )
 
+// ParamAs is the reference fragment parameter for mounting the raw payload as
+// a string at a (dotted) key instead of unmarshalling it. For instance,
+// "gcp-secretmanager://projects/1/secrets/api-key#as=auth.api_key" results in
+// {"auth": {"api_key": "<payload>"}}.
+const ParamAs = "as"
+
 // A Resolver partially implements a Resolver. Matching will have to be
-// implemented separately.
+// implemented separately, using StripFragment to ignore fragment parameters.
 type Resolver struct {
 	Fetch     Fetcher
 	Unmarshal Unmarshaller
 }
 
 func (r Resolver) Resolve(ctx context.Context, reference string) (map[string]any, error) {
+	reference, params, err := splitFragment(reference)
+	if err != nil {
+		return nil, err
+	}
 	data, err := r.Fetch(ctx, reference)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching secret: %w", err)
 	}
+	if as := params.Get(ParamAs); as != "" {
+		return mount(as, string(data)), nil
+	}
 	return r.parse(data)
 }
 
+// ResolveRaw fetches the data for the reference without unmarshalling it.
+// Fragment parameters are ignored.
+func (r Resolver) ResolveRaw(ctx context.Context, reference string) ([]byte, error) {
+	data, err := r.Fetch(ctx, StripFragment(reference))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching secret: %w", err)
+	}
+	return data, nil
+}
+
 func (r Resolver) parse(data []byte) (map[string]any, error) {
 	m := make(map[string]any)
 	if err := r.Unmarshal(data, &m); err != nil {
@@ -36,3 +61,36 @@ func (r Resolver) parse(data []byte) (map[string]any, error) {
 	}
 	return m, nil
 }
+
+// StripFragment returns the reference without its fragment parameters.
+func StripFragment(reference string) string {
+	reference, _, _ = strings.Cut(reference, "#")
+	return reference
+}
+
+func splitFragment(reference string) (string, url.Values, error) {
+	reference, fragment, found := strings.Cut(reference, "#")
+	if !found {
+		return reference, nil, nil
+	}
+	params, err := url.ParseQuery(fragment)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid fragment %q: %w", fragment, err)
+	}
+	for k := range params {
+		if k != ParamAs {
+			return "", nil, fmt.Errorf("unknown fragment parameter %q", k)
+		}
+	}
+	return reference, params, nil
+}
+
+// mount returns a map with the value at the dotted key.
+func mount(key string, v any) map[string]any {
+	parts := strings.Split(key, ".")
+	m := map[string]any{parts[len(parts)-1]: v}
+	for i := len(parts) - 2; i >= 0; i -= 1 {
+		m = map[string]any{parts[i]: m}
+	}
+	return m
+}
@@ -0,0 +1,74 @@
+package base_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/HayoVanLoon/go-slimfig/resolver/base"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	type want struct {
+		value     map[string]any
+		reference string
+		err       require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name      string
+		data      string
+		reference string
+		want      want
+	}{
+		{
+			"unmarshal",
+			`{"a": "b"}`,
+			"scheme://foo",
+			want{map[string]any{"a": "b"}, "scheme://foo", require.NoError},
+		},
+		{
+			"raw at root",
+			"-----BEGIN CERTIFICATE-----\n",
+			"scheme://foo#as=cert",
+			want{
+				map[string]any{"cert": "-----BEGIN CERTIFICATE-----\n"},
+				"scheme://foo",
+				require.NoError,
+			},
+		},
+		{
+			"raw nested",
+			"s3cr3t",
+			"scheme://foo#as=auth.api_key",
+			want{
+				map[string]any{"auth": map[string]any{"api_key": "s3cr3t"}},
+				"scheme://foo",
+				require.NoError,
+			},
+		},
+		{
+			"unknown parameter",
+			"s3cr3t",
+			"scheme://foo#at=auth.api_key",
+			want{nil, "", require.Error},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetched string
+			r := base.Resolver{
+				Fetch: func(_ context.Context, reference string) ([]byte, error) {
+					fetched = reference
+					return []byte(tt.data), nil
+				},
+				Unmarshal: json.Unmarshal,
+			}
+			actual, err := r.Resolve(context.Background(), tt.reference)
+			tt.want.err(t, err)
+			require.Equal(t, tt.want.value, actual)
+			require.Equal(t, tt.want.reference, fetched)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package fake provides in-memory stand-ins for the cloud clients used by the
+// Slimfig resolvers, so these can be used without credentials or network
+// access.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"sync"
+
+	pb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	res "github.com/HayoVanLoon/go-slimfig/resolver"
+)
+
+// AWSSecretsManager is a fake AWS Secrets Manager client. Secrets are looked
+// up by their secret id. When Err is set, it is returned for every call.
+type AWSSecretsManager struct {
+	Secrets map[string]string
+	Err     error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// GetSecretValue returns the secret stored under the input's secret id, or a
+// *types.ResourceNotFoundException when there is none.
+func (f *AWSSecretsManager) GetSecretValue(_ context.Context, params *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	id := aws.ToString(params.SecretId)
+	f.record(id)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	s, ok := f.Secrets[id]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{
+			Message: aws.String("secret not found: " + id),
+		}
+	}
+	return &secretsmanager.GetSecretValueOutput{
+		Name:         aws.String(id),
+		SecretString: aws.String(s),
+	}, nil
+}
+
+// Calls returns the secret ids requested so far, in order.
+func (f *AWSSecretsManager) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *AWSSecretsManager) record(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, id)
+}
+
+// GCPSecretManager is a fake Google Cloud Secret Manager client. Payloads are
+// looked up by their full secret version name, i.e.
+// "projects/p/secrets/s/versions/latest". When Err is set, it is returned for
+// every call.
+type GCPSecretManager struct {
+	Secrets map[string][]byte
+	Err     error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// AccessSecretVersion returns the payload stored under the request's name, or
+// a NotFound status error when there is none. The response includes the
+// payload's CRC32C checksum.
+func (f *GCPSecretManager) AccessSecretVersion(_ context.Context, req *pb.AccessSecretVersionRequest, _ ...gax.CallOption) (*pb.AccessSecretVersionResponse, error) {
+	f.record(req.GetName())
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	data, ok := f.Secrets[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "secret version not found: %s", req.GetName())
+	}
+	sum := int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	return &pb.AccessSecretVersionResponse{
+		Name:    req.GetName(),
+		Payload: &pb.SecretPayload{Data: data, DataCrc32C: &sum},
+	}, nil
+}
+
+// Calls returns the secret version names requested so far, in order.
+func (f *GCPSecretManager) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *GCPSecretManager) record(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, name)
+}
+
+// GCPParameterManager is a fake Google Cloud Parameter Manager client.
+// Rendered payloads are looked up by their full parameter version name, i.e.
+// "projects/p/locations/global/parameters/x/versions/v1". The latest version
+// of a parameter is the one with the (lexicographically) greatest version id.
+// When Err is set, it is returned for every call.
+type GCPParameterManager struct {
+	Versions map[string][]byte
+	Err      error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// RenderParameterVersion returns the payload stored under the name, or an
+// error wrapping resolver.ErrNotFound when there is none.
+func (f *GCPParameterManager) RenderParameterVersion(_ context.Context, name string) ([]byte, error) {
+	f.record(name)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	data, ok := f.Versions[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: parameter version %s", res.ErrNotFound, name)
+	}
+	return data, nil
+}
+
+// LatestParameterVersion returns the name of the parameter's latest version,
+// or an error wrapping resolver.ErrNotFound when it has none.
+func (f *GCPParameterManager) LatestParameterVersion(_ context.Context, parameter string) (string, error) {
+	f.record(parameter)
+	if f.Err != nil {
+		return "", f.Err
+	}
+	latest := ""
+	for name := range f.Versions {
+		if strings.HasPrefix(name, parameter+"/versions/") && name > latest {
+			latest = name
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("%w: parameter %s", res.ErrNotFound, parameter)
+	}
+	return latest, nil
+}
+
+// Calls returns the parameter and parameter version names requested so far,
+// in order.
+func (f *GCPParameterManager) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *GCPParameterManager) record(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, name)
+}
@@ -0,0 +1,53 @@
+package parameter
+
+import (
+	"time"
+
+	"google.golang.org/api/option"
+)
+
+// DefaultTimeout is the default timeout for fetching a single parameter.
+const DefaultTimeout = 5 * time.Second
+
+// An Option modifies the resolver created by Resolver, JSONResolver,
+// YAMLResolver or WithClient.
+type Option func(*options)
+
+type options struct {
+	endpoint      string
+	clientOptions []option.ClientOption
+	timeout       time.Duration
+}
+
+func newOptions(opts []Option) options {
+	o := options{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithEndpoint sets the base URL of the REST API, i.e. "http://localhost:8086"
+// for a local stand-in. This disables the selection of regional endpoints.
+// Local stand-ins will usually also require option.WithoutAuthentication,
+// which can be set with WithClientOptions.
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithClientOptions passes options to the underlying HTTP client.
+func WithClientOptions(opts ...option.ClientOption) Option {
+	return func(o *options) {
+		o.clientOptions = append(o.clientOptions, opts...)
+	}
+}
+
+// WithTimeout sets the timeout for fetching a single parameter, including the
+// lookup of its latest version. Defaults to DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
@@ -0,0 +1,140 @@
+package parameter_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"gopkg.in/yaml.v3"
+
+	res "github.com/HayoVanLoon/go-slimfig/resolver"
+	"github.com/HayoVanLoon/go-slimfig/resolver/fake"
+	"github.com/HayoVanLoon/go-slimfig/resolver/gcp/parameter"
+)
+
+func TestResolver_Matches(t *testing.T) {
+	r := parameter.WithClient(&fake.GCPParameterManager{}, nil)
+	tests := []struct {
+		reference string
+		want      bool
+	}{
+		{"gcp-parametermanager://projects/p/locations/global/parameters/x", true},
+		{"gcp-parametermanager://projects/p/locations/global/parameters/x/versions/v1", true},
+		{"gcp-parametermanager://projects/p/parameters/x", false},
+		{"gcp-parametermanager://projects/p/locations/global/parameters/x/foo/v1", false},
+		{"gcp-secretmanager://projects/p/secrets/x", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reference, func(t *testing.T) {
+			require.Equal(t, tt.want, r.Matches(tt.reference))
+		})
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	const param = "projects/p/locations/global/parameters/x"
+	type want struct {
+		value map[string]any
+		calls []string
+		err   require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name      string
+		client    *fake.GCPParameterManager
+		reference string
+		want      want
+	}{
+		{
+			"explicit version",
+			&fake.GCPParameterManager{
+				Versions: map[string][]byte{
+					param + "/versions/v1": []byte("a:\n  b: c\n"),
+					param + "/versions/v2": []byte("a: 2\n"),
+				},
+			},
+			"gcp-parametermanager://" + param + "/versions/v1",
+			want{
+				map[string]any{"a": map[string]any{"b": "c"}},
+				[]string{param + "/versions/v1"},
+				require.NoError,
+			},
+		},
+		{
+			"latest version",
+			&fake.GCPParameterManager{
+				Versions: map[string][]byte{
+					param + "/versions/v1": []byte("a:\n  b: c\n"),
+					param + "/versions/v2": []byte(`{"a": 2}`),
+				},
+			},
+			"gcp-parametermanager://" + param,
+			want{
+				map[string]any{"a": 2},
+				[]string{param, param + "/versions/v2"},
+				require.NoError,
+			},
+		},
+		{
+			"not found",
+			&fake.GCPParameterManager{},
+			"gcp-parametermanager://" + param,
+			want{nil, []string{param}, errorIs(res.ErrNotFound)},
+		},
+		{
+			"client error",
+			&fake.GCPParameterManager{Err: fmt.Errorf("oh noes")},
+			"gcp-parametermanager://" + param + "/versions/v1",
+			want{nil, []string{param + "/versions/v1"}, require.Error},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := parameter.WithClient(tt.client, yaml.Unmarshal)
+			actual, err := r.Resolve(context.Background(), tt.reference)
+			tt.want.err(t, err)
+			require.Equal(t, tt.want.value, actual)
+			require.Equal(t, tt.want.calls, tt.client.Calls())
+		})
+	}
+}
+
+func TestResolver_rest(t *testing.T) {
+	const param = "projects/p/locations/global/parameters/x"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/"+param+"/versions", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"parameterVersions": [
+			{"name": %q, "createTime": "2025-01-01T00:00:00Z"},
+			{"name": %q, "createTime": "2025-02-01T00:00:00Z"},
+			{"name": %q, "createTime": "2025-03-01T00:00:00Z", "disabled": true}
+		]}`, param+"/versions/v1", param+"/versions/v2", param+"/versions/v3")
+	})
+	mux.HandleFunc("GET /v1/"+param+"/versions/v2:render", func(w http.ResponseWriter, _ *http.Request) {
+		data := base64.StdEncoding.EncodeToString([]byte(`{"a": 1}`))
+		_, _ = fmt.Fprintf(w, `{"renderedPayload": %q}`, data)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	r, err := parameter.JSONResolver(
+		ctx,
+		parameter.WithEndpoint(srv.URL),
+		parameter.WithClientOptions(option.WithoutAuthentication()),
+	)
+	require.NoError(t, err)
+
+	actual, err := r.Resolve(ctx, "gcp-parametermanager://"+param)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": float64(1)}, actual)
+}
+
+func errorIs(target error) require.ErrorAssertionFunc {
+	return func(t require.TestingT, err error, _ ...interface{}) {
+		require.ErrorIs(t, err, target)
+	}
+}
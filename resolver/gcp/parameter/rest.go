@@ -0,0 +1,112 @@
+package parameter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+
+	res "github.com/HayoVanLoon/go-slimfig/resolver"
+)
+
+const scopeCloudPlatform = "https://www.googleapis.com/auth/cloud-platform"
+
+// restClient is a Client using the Parameter Manager REST API (see the package
+// documentation).
+type restClient struct {
+	hc       *http.Client
+	endpoint string
+}
+
+func newRESTClient(ctx context.Context, o options) (*restClient, error) {
+	opts := append([]option.ClientOption{option.WithScopes(scopeCloudPlatform)}, o.clientOptions...)
+	hc, _, err := htransport.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create client: %w", err)
+	}
+	return &restClient{hc: hc, endpoint: o.endpoint}, nil
+}
+
+func (c *restClient) baseURL(name string) string {
+	if c.endpoint != "" {
+		return strings.TrimSuffix(c.endpoint, "/")
+	}
+	if loc := location(name); loc != "" && loc != "global" {
+		return fmt.Sprintf("https://parametermanager.%s.rep.googleapis.com", loc)
+	}
+	return "https://parametermanager.googleapis.com"
+}
+
+func (c *restClient) RenderParameterVersion(ctx context.Context, name string) ([]byte, error) {
+	var resp struct {
+		RenderedPayload []byte `json:"renderedPayload"`
+	}
+	if err := c.get(ctx, c.baseURL(name)+"/v1/"+name+":render", &resp); err != nil {
+		return nil, err
+	}
+	return resp.RenderedPayload, nil
+}
+
+func (c *restClient) LatestParameterVersion(ctx context.Context, parameter string) (string, error) {
+	type version struct {
+		Name       string    `json:"name"`
+		CreateTime time.Time `json:"createTime"`
+		Disabled   bool      `json:"disabled"`
+	}
+	var latest version
+	pageToken := ""
+	for {
+		q := url.Values{"view": {"BASIC"}}
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		var resp struct {
+			ParameterVersions []version `json:"parameterVersions"`
+			NextPageToken     string    `json:"nextPageToken"`
+		}
+		u := c.baseURL(parameter) + "/v1/" + parameter + "/versions?" + q.Encode()
+		if err := c.get(ctx, u, &resp); err != nil {
+			return "", err
+		}
+		for _, v := range resp.ParameterVersions {
+			if !v.Disabled && v.CreateTime.After(latest.CreateTime) {
+				latest = v
+			}
+		}
+		if resp.NextPageToken == "" {
+			break
+		}
+		pageToken = resp.NextPageToken
+	}
+	if latest.Name == "" {
+		return "", fmt.Errorf("%w: no enabled versions for %q", res.ErrNotFound, parameter)
+	}
+	return latest.Name, nil
+}
+
+func (c *restClient) get(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", res.ErrNotFound, u)
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
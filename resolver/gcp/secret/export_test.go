@@ -0,0 +1,25 @@
+package base
+
+import (
+	res "github.com/HayoVanLoon/go-slimfig/resolver"
+	"github.com/HayoVanLoon/go-slimfig/resolver/base"
+)
+
+func Location(name string) string {
+	return location(name)
+}
+
+func RegionalEndpoint(location string) string {
+	return regionalEndpoint(location)
+}
+
+// WithClients returns a resolver using the global client and the regional
+// client factory.
+func WithClients(global Client, regional func(location string) (Client, error), unmarshal base.Unmarshaller) res.Resolver {
+	return resolver{
+		Resolver: base.Resolver{
+			Fetch:     fetchFn(&clients{global: global, regional: regional}, newOptions(nil)),
+			Unmarshal: unmarshal,
+		},
+	}
+}
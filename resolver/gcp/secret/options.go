@@ -0,0 +1,71 @@
+package base
+
+import (
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+)
+
+// DefaultTimeout is the default timeout for fetching a single secret.
+const DefaultTimeout = 5 * time.Second
+
+// An Option modifies the resolver created by Resolver, JSONResolver or
+// WithClient.
+type Option func(*options)
+
+type options struct {
+	endpoint      string
+	clientOptions []option.ClientOption
+	timeout       time.Duration
+	callOptions   []gax.CallOption
+}
+
+func newOptions(opts []Option) options {
+	o := options{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithEndpoint sets the endpoint the client connects to, i.e. "localhost:8085"
+// for a local stand-in. This disables the selection of regional endpoints.
+// Local stand-ins will usually also require option.WithoutAuthentication and
+// an insecure gRPC connection, which can be set with WithClientOptions.
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = endpoint
+		o.clientOptions = append(o.clientOptions, option.WithEndpoint(endpoint))
+	}
+}
+
+// WithClientOptions passes options to the underlying Secret Manager clients.
+func WithClientOptions(opts ...option.ClientOption) Option {
+	return func(o *options) {
+		o.clientOptions = append(o.clientOptions, opts...)
+	}
+}
+
+// WithTimeout sets the timeout for fetching a single secret, including
+// retries. Defaults to DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithRetry retries fetching a secret when the call fails with one of the
+// given codes, waiting between attempts as prescribed by the backoff. When no
+// codes are given, Unavailable and DeadlineExceeded are retried.
+func WithRetry(backoff gax.Backoff, cs ...codes.Code) Option {
+	if len(cs) == 0 {
+		cs = []codes.Code{codes.Unavailable, codes.DeadlineExceeded}
+	}
+	return func(o *options) {
+		o.callOptions = append(o.callOptions, gax.WithRetry(func() gax.Retryer {
+			return gax.OnCodes(cs, backoff)
+		}))
+	}
+}
@@ -4,65 +4,146 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"hash/crc32"
+	"slices"
 	"strings"
-	"time"
+	"sync"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	pb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	res "github.com/HayoVanLoon/go-slimfig/resolver"
 	"github.com/HayoVanLoon/go-slimfig/resolver/base"
 )
 
-var _ res.Resolver = *new(resolver)
+var _ res.RawResolver = *new(resolver)
 
 type resolver struct {
 	base.Resolver
 }
 
 func (r resolver) Matches(reference string) bool {
-	return validName(reference) != ""
+	return validName(base.StripFragment(reference)) != ""
 }
 
 // JSONResolver returns a Secret Manager resolver for secrets containing JSON
 // objects.
-func JSONResolver(ctx context.Context) (res.Resolver, error) {
-	return Resolver(ctx, json.Unmarshal)
+func JSONResolver(ctx context.Context, opts ...Option) (res.Resolver, error) {
+	return Resolver(ctx, json.Unmarshal, opts...)
 }
 
 // Resolver returns a Secret Manager resolver for secrets that can be
-// unmarshalled into maps using the provided function.
-func Resolver(ctx context.Context, unmarshal base.Unmarshaller) (res.Resolver, error) {
-	c, err := secretmanager.NewClient(ctx)
+// unmarshalled into maps using the provided function. The client is created
+// with the default credentials, which can be modified with options.
+//
+// References to regional secrets (projects/p/locations/l/secrets/s) are
+// fetched from the regional endpoint for that location, unless an endpoint
+// has been set with WithEndpoint.
+func Resolver(ctx context.Context, unmarshal base.Unmarshaller, opts ...Option) (res.Resolver, error) {
+	o := newOptions(opts)
+	c, err := secretmanager.NewClient(ctx, o.clientOptions...)
 	if err != nil {
 		return nil, err
 	}
-	return WithClient(c, unmarshal), nil
+	cs := &clients{global: c}
+	if o.endpoint == "" {
+		cs.regional = func(location string) (Client, error) {
+			clientOpts := append(slices.Clip(o.clientOptions), option.WithEndpoint(regionalEndpoint(location)))
+			return secretmanager.NewClient(context.WithoutCancel(ctx), clientOpts...)
+		}
+	}
+	return resolver{
+		Resolver: base.Resolver{
+			Fetch:     fetchFn(cs, o),
+			Unmarshal: unmarshal,
+		},
+	}, nil
+}
+
+// A Client accesses secret versions. It is implemented by
+// *secretmanager.Client, but can be replaced by a fake in tests.
+type Client interface {
+	AccessSecretVersion(ctx context.Context, req *pb.AccessSecretVersionRequest, opts ...gax.CallOption) (*pb.AccessSecretVersionResponse, error)
 }
 
 // WithClient returns a Secret Manager resolver with the given client and
-// unmarshaller.
-func WithClient(c *secretmanager.Client, unmarshal base.Unmarshaller) res.Resolver {
+// unmarshaller. The client is used for all references, regional or not.
+// Options for creating clients are ignored.
+func WithClient(c Client, unmarshal base.Unmarshaller, opts ...Option) res.Resolver {
 	return resolver{
 		Resolver: base.Resolver{
-			Fetch:     fetchFn(c),
+			Fetch:     fetchFn(&clients{global: c}, newOptions(opts)),
 			Unmarshal: unmarshal,
 		},
 	}
 }
 
-func fetchFn(c *secretmanager.Client) base.Fetcher {
+// clients holds the global client and lazily creates clients for regional
+// endpoints.
+type clients struct {
+	global   Client
+	regional func(location string) (Client, error)
+
+	mu    sync.Mutex
+	cache map[string]Client
+}
+
+func (cs *clients) get(location string) (Client, error) {
+	if location == "" || cs.regional == nil {
+		return cs.global, nil
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if c, ok := cs.cache[location]; ok {
+		return c, nil
+	}
+	c, err := cs.regional(location)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client for location %q: %w", location, err)
+	}
+	if cs.cache == nil {
+		cs.cache = make(map[string]Client)
+	}
+	cs.cache[location] = c
+	return c, nil
+}
+
+func regionalEndpoint(location string) string {
+	return fmt.Sprintf("secretmanager.%s.rep.googleapis.com:443", location)
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+func fetchFn(cs *clients, o options) base.Fetcher {
 	return func(ctx context.Context, reference string) ([]byte, error) {
+		name := validName(reference)
+		c, err := cs.get(location(name))
+		if err != nil {
+			return nil, err
+		}
 		req := &pb.AccessSecretVersionRequest{
-			Name: validName(reference),
+			Name: name,
 		}
-		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		ctx, cancel := context.WithTimeout(ctx, o.timeout)
 		defer cancel()
-		resp, err := c.AccessSecretVersion(ctx, req)
+		resp, err := c.AccessSecretVersion(ctx, req, o.callOptions...)
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("%w: %w", res.ErrNotFound, err)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error fetching secret: %w", err)
 		}
-		return resp.Payload.Data, nil
+		data := resp.GetPayload().GetData()
+		if sum := resp.GetPayload().DataCrc32C; sum != nil {
+			if actual := int64(crc32.Checksum(data, crc32c)); actual != *sum {
+				return nil, fmt.Errorf("checksum mismatch for %q: expected %d, got %d", name, *sum, actual)
+			}
+		}
+		return data, nil
 	}
 }
 
@@ -81,9 +162,12 @@ func validName(s string) string {
 			return s + "/versions/latest"
 		}
 	case 6:
-		if xs[0] == "projects" && xs[2] == "secrets" {
+		if xs[0] == "projects" && xs[2] == "secrets" && xs[4] == "versions" {
 			return s
 		}
+		if xs[0] == "projects" && xs[2] == "locations" && xs[4] == "secrets" {
+			return s + "/versions/latest"
+		}
 	case 8:
 		if xs[0] == "projects" && xs[2] == "locations" && xs[4] == "secrets" {
 			return s
@@ -91,3 +175,13 @@ func validName(s string) string {
 	}
 	return ""
 }
+
+// location returns the location of a regional secret version name, or an
+// empty string for global secrets.
+func location(name string) string {
+	xs := strings.Split(name, "/")
+	if len(xs) > 3 && xs[2] == "locations" {
+		return xs[3]
+	}
+	return ""
+}
@@ -0,0 +1,293 @@
+package base_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	pb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	res "github.com/HayoVanLoon/go-slimfig/resolver"
+	"github.com/HayoVanLoon/go-slimfig/resolver/fake"
+	secret "github.com/HayoVanLoon/go-slimfig/resolver/gcp/secret"
+)
+
+func TestResolver_Matches(t *testing.T) {
+	r := secret.WithClient(&fake.GCPSecretManager{}, json.Unmarshal)
+	tests := []struct {
+		reference string
+		want      bool
+	}{
+		{"gcp-secretmanager://projects/1/secrets/foo", true},
+		{"gcp-secretmanager://projects/1/secrets/foo#as=auth.api_key", true},
+		{"gcp-secretmanager://projects/1/secrets/foo/versions/2", true},
+		{"gcp-secretmanager://projects/1/locations/eu/secrets/foo/versions/2", true},
+		{"gcp-secretmanager://projects/1/locations/eu/secrets/foo", true},
+		{"gcp-secretmanager://projects/1/locations/eu/parameters/foo", false},
+		{"gcp-secretmanager://projects/1/foo", false},
+		{"aws-secretsmanager://foo", false},
+		{"projects/1/secrets/foo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reference, func(t *testing.T) {
+			require.Equal(t, tt.want, r.Matches(tt.reference))
+		})
+	}
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	type want struct {
+		value map[string]any
+		calls []string
+		err   require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name      string
+		client    *fake.GCPSecretManager
+		reference string
+		want      want
+	}{
+		{
+			"happy",
+			&fake.GCPSecretManager{
+				Secrets: map[string][]byte{
+					"projects/1/secrets/foo/versions/latest": []byte(`{"a": {"b": "c"}}`),
+				},
+			},
+			"gcp-secretmanager://projects/1/secrets/foo",
+			want{
+				map[string]any{"a": map[string]any{"b": "c"}},
+				[]string{"projects/1/secrets/foo/versions/latest"},
+				require.NoError,
+			},
+		},
+		{
+			"raw value",
+			&fake.GCPSecretManager{
+				Secrets: map[string][]byte{
+					"projects/1/secrets/foo/versions/latest": []byte("s3cr3t"),
+				},
+			},
+			"gcp-secretmanager://projects/1/secrets/foo#as=auth.api_key",
+			want{
+				map[string]any{"auth": map[string]any{"api_key": "s3cr3t"}},
+				[]string{"projects/1/secrets/foo/versions/latest"},
+				require.NoError,
+			},
+		},
+		{
+			"explicit version",
+			&fake.GCPSecretManager{
+				Secrets: map[string][]byte{
+					"projects/1/secrets/foo/versions/2": []byte(`{"a": 1}`),
+				},
+			},
+			"gcp-secretmanager://projects/1/secrets/foo/versions/2",
+			want{
+				map[string]any{"a": float64(1)},
+				[]string{"projects/1/secrets/foo/versions/2"},
+				require.NoError,
+			},
+		},
+		{
+			"not found",
+			&fake.GCPSecretManager{},
+			"gcp-secretmanager://projects/1/secrets/foo",
+			want{nil, []string{"projects/1/secrets/foo/versions/latest"}, errorIs(res.ErrNotFound)},
+		},
+		{
+			"client error",
+			&fake.GCPSecretManager{Err: fmt.Errorf("oh noes")},
+			"gcp-secretmanager://projects/1/secrets/foo",
+			want{nil, []string{"projects/1/secrets/foo/versions/latest"}, require.Error},
+		},
+		{
+			"invalid json",
+			&fake.GCPSecretManager{
+				Secrets: map[string][]byte{
+					"projects/1/secrets/foo/versions/latest": []byte("not json"),
+				},
+			},
+			"gcp-secretmanager://projects/1/secrets/foo",
+			want{nil, []string{"projects/1/secrets/foo/versions/latest"}, require.Error},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := secret.WithClient(tt.client, json.Unmarshal)
+			actual, err := r.Resolve(context.Background(), tt.reference)
+			tt.want.err(t, err)
+			require.Equal(t, tt.want.value, actual)
+			require.Equal(t, tt.want.calls, tt.client.Calls())
+		})
+	}
+}
+
+type secretManagerServer struct {
+	pb.UnimplementedSecretManagerServiceServer
+	failures int
+	calls    int
+}
+
+func (s *secretManagerServer) AccessSecretVersion(_ context.Context, req *pb.AccessSecretVersionRequest) (*pb.AccessSecretVersionResponse, error) {
+	s.calls += 1
+	if s.calls <= s.failures {
+		return nil, status.Error(codes.Internal, "try again")
+	}
+	return &pb.AccessSecretVersionResponse{
+		Name:    req.GetName(),
+		Payload: &pb.SecretPayload{Data: []byte(`{"a": 1}`)},
+	}, nil
+}
+
+func TestResolver_options(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	pb.RegisterSecretManagerServiceServer(srv, &secretManagerServer{})
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	ctx := context.Background()
+	r, err := secret.JSONResolver(
+		ctx,
+		secret.WithEndpoint(lis.Addr().String()),
+		secret.WithClientOptions(
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		),
+	)
+	require.NoError(t, err)
+
+	actual, err := r.Resolve(ctx, "gcp-secretmanager://projects/1/secrets/foo")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": float64(1)}, actual)
+}
+
+type clientFunc func(ctx context.Context, req *pb.AccessSecretVersionRequest) (*pb.AccessSecretVersionResponse, error)
+
+func (f clientFunc) AccessSecretVersion(ctx context.Context, req *pb.AccessSecretVersionRequest, _ ...gax.CallOption) (*pb.AccessSecretVersionResponse, error) {
+	return f(ctx, req)
+}
+
+func TestResolver_checksum(t *testing.T) {
+	wrong := int64(42)
+	c := clientFunc(func(_ context.Context, req *pb.AccessSecretVersionRequest) (*pb.AccessSecretVersionResponse, error) {
+		return &pb.AccessSecretVersionResponse{
+			Name:    req.GetName(),
+			Payload: &pb.SecretPayload{Data: []byte(`{"a": 1}`), DataCrc32C: &wrong},
+		}, nil
+	})
+	r := secret.WithClient(c, json.Unmarshal)
+	_, err := r.Resolve(context.Background(), "gcp-secretmanager://projects/1/secrets/foo")
+	require.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestResolver_timeout(t *testing.T) {
+	c := clientFunc(func(ctx context.Context, _ *pb.AccessSecretVersionRequest) (*pb.AccessSecretVersionResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	r := secret.WithClient(c, json.Unmarshal, secret.WithTimeout(time.Millisecond))
+	_, err := r.Resolve(context.Background(), "gcp-secretmanager://projects/1/secrets/foo")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestResolver_retry(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	server := &secretManagerServer{failures: 2}
+	pb.RegisterSecretManagerServiceServer(srv, server)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	ctx := context.Background()
+	r, err := secret.JSONResolver(
+		ctx,
+		secret.WithEndpoint(lis.Addr().String()),
+		secret.WithClientOptions(
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		),
+		secret.WithRetry(gax.Backoff{Initial: time.Millisecond}, codes.Internal),
+	)
+	require.NoError(t, err)
+
+	actual, err := r.Resolve(ctx, "gcp-secretmanager://projects/1/secrets/foo")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": float64(1)}, actual)
+	require.Equal(t, 3, server.calls)
+}
+
+func TestResolver_regional(t *testing.T) {
+	global := &fake.GCPSecretManager{}
+	regional := map[string]*fake.GCPSecretManager{
+		"europe-west4": {Secrets: map[string][]byte{
+			"projects/1/locations/europe-west4/secrets/foo/versions/latest": []byte(`{"a": 1}`),
+			"projects/1/locations/europe-west4/secrets/bar/versions/2":      []byte(`{"b": 2}`),
+		}},
+	}
+	var created []string
+	r := secret.WithClients(global, func(location string) (secret.Client, error) {
+		created = append(created, location)
+		c, ok := regional[location]
+		if !ok {
+			return nil, fmt.Errorf("unknown location")
+		}
+		return c, nil
+	}, json.Unmarshal)
+	ctx := context.Background()
+
+	actual, err := r.Resolve(ctx, "gcp-secretmanager://projects/1/locations/europe-west4/secrets/foo")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": float64(1)}, actual)
+	actual, err = r.Resolve(ctx, "gcp-secretmanager://projects/1/locations/europe-west4/secrets/bar/versions/2")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"b": float64(2)}, actual)
+	_, err = r.Resolve(ctx, "gcp-secretmanager://projects/1/locations/mars/secrets/foo")
+	require.ErrorContains(t, err, `error creating client for location "mars"`)
+
+	require.Equal(t, []string{"europe-west4", "mars"}, created)
+	require.Equal(t, []string{
+		"projects/1/locations/europe-west4/secrets/foo/versions/latest",
+		"projects/1/locations/europe-west4/secrets/bar/versions/2",
+	}, regional["europe-west4"].Calls())
+	require.Empty(t, global.Calls())
+}
+
+func TestLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     string
+		endpoint string
+	}{
+		{"projects/1/secrets/foo/versions/latest", "", ""},
+		{"projects/1/locations/europe-west4/secrets/foo/versions/2", "europe-west4", "secretmanager.europe-west4.rep.googleapis.com:443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := secret.Location(tt.name)
+			require.Equal(t, tt.want, actual)
+			if actual != "" {
+				require.Equal(t, tt.endpoint, secret.RegionalEndpoint(actual))
+			}
+		})
+	}
+}
+
+func errorIs(target error) require.ErrorAssertionFunc {
+	return func(t require.TestingT, err error, _ ...interface{}) {
+		require.ErrorIs(t, err, target)
+	}
+}
@@ -4,6 +4,9 @@ package json
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"strings"
 
@@ -12,7 +15,7 @@ import (
 
 const ProtocolFile = "file://"
 
-var _ res.Resolver = *new(resolver)
+var _ res.RawResolver = *new(resolver)
 
 type resolver struct {
 	extensions []string
@@ -30,6 +33,9 @@ func (r resolver) Matches(reference string) bool {
 func (r resolver) Resolve(_ context.Context, reference string) (map[string]any, error) {
 	reference = strings.TrimPrefix(reference, ProtocolFile)
 	f, err := os.Open(reference) //nolint:gosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", res.ErrNotFound, err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +44,15 @@ func (r resolver) Resolve(_ context.Context, reference string) (map[string]any,
 	return m, json.NewDecoder(f).Decode(&m)
 }
 
+func (r resolver) ResolveRaw(_ context.Context, reference string) ([]byte, error) {
+	reference = strings.TrimPrefix(reference, ProtocolFile)
+	data, err := os.ReadFile(reference) //nolint:gosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", res.ErrNotFound, err)
+	}
+	return data, err
+}
+
 // Resolver returns a resolver for JSON files. By default, it only matches
 // references ending in ".json".
 func Resolver(extensions ...string) res.Resolver {
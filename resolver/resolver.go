@@ -1,10 +1,26 @@
 package resolver
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned (wrapped) by resolvers when the referenced
+// configuration map does not exist.
+var ErrNotFound = errors.New("not found")
 
 type Resolver interface {
 	// Matches returns true when the resolver is able to handle this reference.
 	Matches(reference string) bool
-	// Resolve resolves the reference to a map.
+	// Resolve resolves the reference to a map. If the referenced map does
+	// not exist, the returned error should wrap ErrNotFound.
 	Resolve(ctx context.Context, reference string) (map[string]any, error)
 }
+
+// A RawResolver is a Resolver that can also provide the unparsed data for a
+// reference, like the plain text value of a secret.
+type RawResolver interface {
+	Resolver
+	// ResolveRaw resolves the reference to its unparsed data.
+	ResolveRaw(ctx context.Context, reference string) ([]byte, error)
+}
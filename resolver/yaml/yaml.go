@@ -3,6 +3,9 @@ package yaml
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"strings"
 
@@ -13,7 +16,7 @@ import (
 
 const ProtocolFile = "file://"
 
-var _ res.Resolver = *new(resolver)
+var _ res.RawResolver = *new(resolver)
 
 type resolver struct {
 	extensions []string
@@ -31,16 +34,65 @@ func (r resolver) Matches(reference string) bool {
 func (r resolver) Resolve(_ context.Context, reference string) (map[string]any, error) {
 	reference = strings.TrimPrefix(reference, ProtocolFile)
 	f, err := os.Open(reference) //nolint:gosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", res.ErrNotFound, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = f.Close() }()
+	var n yaml.Node
+	if err = yaml.NewDecoder(f).Decode(&n); err != nil {
+		return nil, err
+	}
+	applyTags(&n)
 	m := make(map[string]any)
-	return m, yaml.NewDecoder(f).Decode(&m)
+	return m, n.Decode(&m)
+}
+
+// applyTags translates the merge tags into Slimfig merge directives:
+//   - a mapping tagged "!replace" gets a "$replace: true" entry
+//   - a sequence tagged "!append" becomes a mapping with an "$append" key
+//   - a sequence tagged "!prepend" becomes a mapping with a "$prepend" key
+func applyTags(n *yaml.Node) {
+	for _, c := range n.Content {
+		applyTags(c)
+	}
+	switch {
+	case n.Kind == yaml.MappingNode && n.Tag == "!replace":
+		n.Tag = "!!map"
+		n.Content = append([]*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "$replace"},
+			{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"},
+		}, n.Content...)
+	case n.Kind == yaml.SequenceNode && (n.Tag == "!append" || n.Tag == "!prepend"):
+		seq := *n
+		seq.Tag = "!!seq"
+		*n = yaml.Node{
+			Kind: yaml.MappingNode,
+			Tag:  "!!map",
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: "$" + n.Tag[1:]},
+				&seq,
+			},
+		}
+	}
+}
+
+func (r resolver) ResolveRaw(_ context.Context, reference string) ([]byte, error) {
+	reference = strings.TrimPrefix(reference, ProtocolFile)
+	data, err := os.ReadFile(reference) //nolint:gosec
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", res.ErrNotFound, err)
+	}
+	return data, err
 }
 
 // Resolver returns a resolver for YAML files. By default, it only matches
 // references ending in ".yaml".
+//
+// Besides the merge directives in the maps themselves, the resolver supports
+// the tags "!replace" for mappings and "!append" and "!prepend" for sequences.
 func Resolver(extensions ...string) res.Resolver {
 	if len(extensions) == 0 {
 		extensions = []string{".yaml"}
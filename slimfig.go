@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/HayoVanLoon/go-slimfig/resolver"
 	jsonresolver "github.com/HayoVanLoon/go-slimfig/resolver/json"
@@ -24,6 +28,33 @@ func SetResolvers(rs ...resolver.Resolver) {
 	resolvers = rs
 }
 
+// DefaultConcurrency is the default maximum number of references that are
+// resolved at the same time.
+const DefaultConcurrency = 8
+
+var concurrency = DefaultConcurrency
+
+// SetConcurrency sets the maximum number of references that are resolved at
+// the same time. Values below one are treated as one, which means references
+// are resolved sequentially.
+//
+// Regardless of the order in which they are resolved, references are always
+// merged in scheme order.
+func SetConcurrency(n int) {
+	concurrency = max(n, 1)
+}
+
+// findResolver returns the first resolver matching the reference, or nil if
+// there is none.
+func findResolver(reference string) resolver.Resolver {
+	for _, r := range resolvers {
+		if r.Matches(reference) {
+			return r
+		}
+	}
+	return nil
+}
+
 // EnvSuffix suffix added to the prefix to build the configuration scheme
 // environment variable, i.e.:
 //
@@ -36,11 +67,22 @@ const EnvSuffix = "CONFIG"
 // The configuration scheme is a list of references to configuration maps,
 // which are resolved during initialisation. The first map forms the base and
 // each subsequent one modifies this base by adding or overwriting values.
+// Merging can be controlled with directives (see DirectiveReplace), and a
+// null value deletes a key set by an earlier reference.
 //
 // When both prefix and references are specified, the environment variable
 // will take precedence, provided its value is non-empty. The configuration
 // scheme environment variable is constructed by appending "_CONFIG" to the
-// prefix; for instance "XX_CONFIG" for prefix "XX".
+// prefix; for instance "XX_CONFIG" for prefix "XX". The "--config" flag
+// takes precedence over both (see ConfigFlag). An empty scheme can be
+// discovered (see SetDiscovery), and profiles add optional siblings to every
+// reference (see EnvProfileSuffix).
+//
+// References take the form "[?][patch+]target[#[path][@key]]". A leading "?"
+// makes a reference optional, "patch+" makes it a patch (see PatchPrefix), and
+// the fragment selects a subtree and/or mounts the result under a key, i.e.
+// "platform.yaml#services.billing@billing". Maps can include other maps (see
+// IncludeKey).
 //
 // If a prefix has been specified, other environment variable starting with it
 // will be pulled into the configuration. First their names are translated into
@@ -50,10 +92,24 @@ const EnvSuffix = "CONFIG"
 //   - double underscores are translated into dots
 //
 // For instance, given prefix "XX", "XX_service__Host_Name" becomes
-// "service.Host_Name". Notice that the casing is being preserved.
+// "service.Host_Name". Notice that the casing is being preserved. See
+// SetEnvMapper, SetEnvTyping and SetEnvPolicy to change how variables are
+// applied.
+//
+// After merging the scheme, Load takes the following steps:
+//  1. applying the environment variables starting with the prefix
+//  2. applying the environment variables of keys declared with Define
+//  3. applying "--set" flags (see SetFlag)
+//  4. resolving inline references (see InlinePrefix)
+//  5. interpolating "${env:VAR}", "${key.path}" and "${key.path:-default}"
+//     in string values, where "$${" escapes a reference
+//  6. checking that required keys are present (see Required)
 //
-// Initialisation is all-or-nothing, so in case of any error, the configuration
-// will remain uninitialised.
+// References are resolved concurrently (see SetConcurrency) within the
+// deadline of the context. Initialisation is all-or-nothing, so in case of any
+// error, the configuration will remain uninitialised. The returned error is a
+// *LoadError listing all failed references. A report on the resolution of the
+// references is available via Report.
 //
 // This method should only be called once. Subsequent calls will always reset
 // the configuration.
@@ -61,20 +117,77 @@ const EnvSuffix = "CONFIG"
 // When using custom resolvers, these must be set via SetResolvers prior to
 // calling this method.
 func Load(ctx context.Context, prefix string, references ...string) error {
-	if prefix != "" {
-		if s := os.Getenv(prefix + "_" + EnvSuffix); s != "" {
-			references = strings.Split(s, ",")
-		}
+	flagScheme, set, err := flagValues()
+	if err != nil {
+		reset()
+		return err
 	}
+	references = schemeReferences(prefix, references, flagScheme)
 	if err := loadScheme(ctx, references); err != nil {
 		return err
 	}
 	if prefix != "" {
-		loadEnvironment(prefix)
+		skipped := loadEnvironment(prefix)
+		lastReport.SkippedEnv = skipped
+		if len(skipped) > 0 && envPolicy != nil && envPolicy.Reject {
+			reset()
+			return envError(skipped)
+		}
+	}
+	applyDefinedEnv()
+	if err := applySetFlags(set); err != nil {
+		reset()
+		return err
+	}
+	if err := resolveInline(ctx, config); err != nil {
+		reset()
+		return err
+	}
+	if err := interpolate(config); err != nil {
+		reset()
+		return err
+	}
+	if err := checkRequired(); err != nil {
+		reset()
+		return err
 	}
 	return nil
 }
 
+// Validate resolves the configuration scheme like Load, but without changing
+// the configuration or pulling in environment variables. Unlike Load, it
+// attempts to resolve every reference, even if some of them cannot be matched
+// to a resolver.
+//
+// The report is always returned. If any reference fails, the error is a
+// *LoadError listing all failed references.
+func Validate(ctx context.Context, prefix string, references ...string) (*LoadReport, error) {
+	flagScheme, _, err := flagValues()
+	if err != nil {
+		return &LoadReport{}, err
+	}
+	references = schemeReferences(prefix, references, flagScheme)
+	_, report, err := resolveScheme(ctx, references, true)
+	return report, err
+}
+
+// schemeReferences determines the references of the configuration scheme. The
+// scheme from the command-line flags takes precedence over the one from the
+// environment, which takes precedence over the given references.
+func schemeReferences(prefix string, references, flagScheme []string) []string {
+	if len(flagScheme) > 0 {
+		references = flagScheme
+	} else if prefix != "" {
+		if s := os.Getenv(prefix + "_" + EnvSuffix); s != "" {
+			references = strings.Split(s, ",")
+		}
+	}
+	if len(references) == 0 && discovery != nil {
+		references = discovery.Find()
+	}
+	return expandProfiles(references, profiles(prefix))
+}
+
 type configMap map[string]any
 
 func (m configMap) get(key string) (any, bool) {
@@ -85,20 +198,27 @@ func (m configMap) get2(key []string) (any, bool) {
 	if len(key) == 0 {
 		return nil, false
 	}
-	v, ok := m[key[0]]
-	if !ok {
-		return nil, false
-	}
-	if len(key) == 1 {
-		return v, true
-	}
-	m2, ok := v.(map[string]any)
-	if !ok {
-		if m2, ok = toMap(v, toAny); !ok {
+	var v any = map[string]any(m)
+	for _, k := range key {
+		if xs, ok := toSlice(v, toAny); ok {
+			i, ok := listIndex(k, len(xs)-1)
+			if !ok {
+				return nil, false
+			}
+			v = xs[i]
+			continue
+		}
+		m2, ok := v.(map[string]any)
+		if !ok {
+			if m2, ok = toMap(v, toAny); !ok {
+				return nil, false
+			}
+		}
+		if v, ok = m2[k]; !ok {
 			return nil, false
 		}
 	}
-	return configMap(m2).get2(key[1:])
+	return v, true
 }
 
 func (m configMap) getPointer(key string) (*any, bool) {
@@ -117,86 +237,249 @@ func reset() {
 
 func loadScheme(ctx context.Context, references []string) error {
 	reset()
+	out, report, err := resolveScheme(ctx, references, false)
+	lastReport = report
+	if err != nil {
+		return err
+	}
+	config = out
+	return nil
+}
+
+// resolveScheme resolves and merges the references. Unless all is set,
+// references are only resolved if every reference has a matching resolver.
+// Any error returned is a *LoadError.
+func resolveScheme(ctx context.Context, references []string, all bool) (configMap, *LoadReport, error) {
+	refs := make([]reference, len(references))
 	rs := make([]resolver.Resolver, len(references))
-	for i, ref := range references {
-		ref = strings.TrimSpace(ref)
-		found := false
-		for _, r := range resolvers {
-			if found = r.Matches(ref); found {
-				rs[i] = r
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("no resolver for %q", ref)
+	report := &LoadReport{References: make([]ReferenceReport, len(references))}
+	for i := range references {
+		refs[i] = parseReference(references[i])
+		report.References[i].Reference = refs[i].raw
+		rs[i] = findResolver(refs[i].target)
+		report.References[i].Resolver = resolverName(rs[i])
+		if rs[i] == nil {
+			report.References[i].Err = &ReferenceError{Reference: refs[i].raw, Err: ErrNoResolver}
 		}
 	}
+	if err := report.err(); err != nil && !all {
+		return nil, report, err
+	}
 
+	results := resolveAll(ctx, refs, rs, report)
+	if err := report.err(); err != nil {
+		return nil, report, err
+	}
 	out := configMap{}
-	for i := range rs {
-		cfg, err := rs[i].Resolve(ctx, references[i])
+	for i, res := range results {
+		if res.patch == nil {
+			merge(&out, res.cfg)
+			continue
+		}
+		m, err := res.patch.apply(out)
 		if err != nil {
-			return fmt.Errorf("error resolving %q: %w", references[i], err)
+			rep := &report.References[i]
+			rep.Err = &ReferenceError{Reference: refs[i].raw, Resolver: rep.Resolver, Err: err}
+			return nil, report, report.err()
 		}
-		merge(&out, cfg)
+		out = m
 	}
-	config = out
+	return out, report, nil
+}
+
+// A result is a resolved reference: either a map to merge or a patch to apply.
+type result struct {
+	cfg   map[string]any
+	patch patch
+}
+
+// resolveAll resolves the references concurrently, using at most concurrency
+// workers, and records the results in the report. References without a
+// resolver are ignored. The results are returned in reference order; failed
+// and skipped references result in an empty result.
+func resolveAll(ctx context.Context, refs []reference, rs []resolver.Resolver, report *LoadReport) []result {
+	results := make([]result, len(refs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range refs {
+		if rs[i] == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rep := &report.References[i]
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				rep.Err = &ReferenceError{Reference: refs[i].raw, Resolver: rep.Resolver, Err: ctx.Err()}
+				return
+			}
+			start := time.Now()
+			var err error
+			if refs[i].patch {
+				err = resolvePatch(ctx, rs[i], refs[i], &results[i], rep)
+			} else {
+				err = resolveMap(ctx, rs[i], refs[i], &results[i], rep)
+			}
+			rep.Duration = time.Since(start)
+			if err != nil {
+				rep.Err = &ReferenceError{Reference: refs[i].raw, Resolver: rep.Resolver, Err: err}
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func resolveMap(ctx context.Context, r resolver.Resolver, ref reference, res *result, rep *ReferenceReport) error {
+	cfg, err := resolve(ctx, r, ref)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		rep.Skipped = true
+		return nil
+	}
+	rep.Size = size(cfg)
+	rep.Keys = countKeys(cfg)
+	res.cfg = cfg
 	return nil
 }
 
+// resolve resolves a single reference. Returns a nil map if the reference is
+// optional and could not be found.
+func resolve(ctx context.Context, r resolver.Resolver, ref reference) (map[string]any, error) {
+	return resolveChain(ctx, r, ref, []string{ref.target})
+}
+
+// resolveChain resolves a reference that has been included via the chain of
+// references.
+func resolveChain(ctx context.Context, r resolver.Resolver, ref reference, chain []string) (map[string]any, error) {
+	cfg, err := r.Resolve(ctx, ref.target)
+	if ref.optional && errors.Is(err, resolver.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg, err = includes(ctx, cfg, chain); err != nil {
+		return nil, err
+	}
+	if cfg, err = ref.apply(cfg); err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = map[string]any{}
+	}
+	return cfg, nil
+}
+
 func merge(old *configMap, m map[string]any) {
+	mergeAt(old, m, "")
+}
+
+// mergeAt merges the map into the map at the given (dotted) path.
+func mergeAt(old *configMap, m map[string]any, path string) {
 	for k, v := range m {
+		if k == DirectiveReplace {
+			continue
+		}
+		if v == nil {
+			// null only deletes keys that have been set before
+			if _, ok := (*old)[k]; ok {
+				delete(*old, k)
+			} else {
+				(*old)[k] = nil
+			}
+			continue
+		}
 		ovp, ok := (*old).getPointer(k)
 		if !ok {
-			(*old)[k] = v
+			(*old)[k] = withoutDirectives(v)
 			continue
 		}
+		p := joinKey(path, k)
 		vm, ok := v.(map[string]any)
 		if !ok {
+			if key := mergeKeys[p]; key != "" {
+				if xs, ok := keyedMerge(*ovp, v, key, p); ok {
+					(*old)[k] = xs
+					continue
+				}
+			}
 			(*old)[k] = v
 			continue
 		}
+		if key, ok := vm[DirectiveMergeKey].(string); ok {
+			// without a list of items, the existing list is left unchanged
+			if xs, ok := keyedMerge(*ovp, vm[DirectiveItems], key, p); ok {
+				(*old)[k] = xs
+			}
+			continue
+		}
+		if xs, ok := listDirective(*ovp, vm); ok {
+			(*old)[k] = xs
+			continue
+		}
+		if replace, _ := vm[DirectiveReplace].(bool); replace {
+			(*old)[k] = withoutDirectives(vm)
+			continue
+		}
 		ovm, ok := (*ovp).(map[string]any)
 		if !ok {
 			// maps should be string-any, if it is a map: convert it
 			ovm, ok = toMap(*ovp, toAny)
 			if !ok {
-				(*old)[k] = v
+				(*old)[k] = withoutDirectives(v)
 				continue
 			}
 			(*old)[k] = ovm
 		}
-		merge((*configMap)(&ovm), vm)
+		mergeAt((*configMap)(&ovm), vm, p)
 	}
 }
 
-func loadEnvironment(prefix string) {
+// loadEnvironment applies the environment variables starting with the prefix
+// to the configuration. Returns the errors for the variables that were
+// skipped.
+func loadEnvironment(prefix string) []*EnvError {
 	prefix += "_"
-	for _, kv := range os.Environ() {
-		k, v, ok := strings.Cut(kv, "=")
+	envs := os.Environ()
+	slices.Sort(envs)
+	var skipped []*EnvError
+	for _, kv := range envs {
+		name, v, ok := strings.Cut(kv, "=")
 		if !ok {
 			continue
 		}
-		if _, k, ok = strings.Cut(k, prefix); ok && k != EnvSuffix {
-			addEnv(&config, k, v)
+		k, ok := strings.CutPrefix(name, prefix)
+		if !ok || k == EnvSuffix || k == EnvProfileSuffix {
+			continue
+		}
+		parts := envMapper(k, config)
+		if len(parts) == 0 {
+			continue
 		}
+		existing, exists := config.get2(parts)
+		value := envValue(v, existing, exists)
+		if err := envPolicy.check(config, parts, value); err != nil {
+			envErr := &EnvError{Variable: name, Key: strings.Join(parts, "."), Err: err}
+			if errors.Is(err, ErrEnvUnknownKey) {
+				envErr.Suggestions = envPolicy.suggestions(config, envErr.Key)
+			}
+			skipped = append(skipped, envErr)
+			continue
+		}
+		config = setAt(map[string]any(config), parts, value).(map[string]any)
 	}
+	return skipped
 }
 
-func addEnv(old *configMap, k string, v string) {
-	parts := strings.Split(k, "__")
-	if len(parts) == 1 {
-		(*old)[k] = v
-	}
-	m := make(map[string]any)
-	p := &m
-	for i := 0; i < len(parts)-1; i += 1 {
-		p2 := &map[string]any{}
-		(*p)[parts[i]] = *p2
-		p = p2
-	}
-	(*p)[parts[len(parts)-1]] = v
-	merge(old, m)
+func addEnv(old *configMap, parts []string, v string) {
+	existing, exists := old.get2(parts)
+	*old = setAt(map[string]any(*old), parts, envValue(v, existing, exists)).(map[string]any)
 }
 
 // String looks up a configuration value as a string. If the stored value is
@@ -262,6 +545,22 @@ func Bool(key string, fallback bool) bool {
 	return b
 }
 
+// Duration looks up a configuration value as a duration. String values are
+// parsed with time.ParseDuration, i.e. "1m30s".
+//
+// Returns the fallback when the lookup fails or the value cannot be converted.
+func Duration(key string, fallback time.Duration) time.Duration {
+	a, ok := config.get(key)
+	if !ok {
+		return fallback
+	}
+	d, ok := toDuration(a)
+	if !ok {
+		return fallback
+	}
+	return d
+}
+
 // Any looks up a configuration value. Returns the fallback when the lookup
 // fails.
 func Any(key string, fallback any) any {
@@ -274,7 +573,8 @@ func Any(key string, fallback any) any {
 
 // StringSlice looks up a configuration value as a slice of strings. If the
 // stored value is a slice, but not one of strings, it will convert non-string
-// values using their standard string representation ("%v").
+// values using their standard string representation ("%v"). A string value is
+// split into items (see SetListSeparator).
 //
 // Returns the fallback when the lookup fails.
 func StringSlice(key string, fallback []string) []string {
@@ -282,7 +582,7 @@ func StringSlice(key string, fallback []string) []string {
 	if !ok {
 		return fallback
 	}
-	out, ok := toSlice(a, toString2)
+	out, ok := toList(a, toString2)
 	if !ok {
 		return fallback
 	}
@@ -293,12 +593,14 @@ func StringSlice(key string, fallback []string) []string {
 // stored value is a slice, but not one of integers, it will attempt to convert
 // or parse the values. If this fails for any item, the fallback is returned.
 // Also returns the fallback when the lookup fails.
+//
+// A string value is split into items first (see SetListSeparator).
 func IntSlice(key string, fallback []int) []int {
 	a, ok := config.get(key)
 	if !ok {
 		return fallback
 	}
-	out, ok := toSlice(a, toInt)
+	out, ok := toList(a, toInt)
 	if !ok {
 		return fallback
 	}
@@ -309,12 +611,14 @@ func IntSlice(key string, fallback []int) []int {
 // numbers. If the stored value is a slice, but not one of floating points, it
 // will attempt to convert or parse the values. If this fails for any item, the
 // fallback is returned. Also returns the fallback when the lookup fails.
+//
+// A string value is split into items first (see SetListSeparator).
 func FloatSlice(key string, fallback []float64) []float64 {
 	a, ok := config.get(key)
 	if !ok {
 		return fallback
 	}
-	out, ok := toSlice(a, toFloat64)
+	out, ok := toList(a, toFloat64)
 	if !ok {
 		return fallback
 	}
@@ -325,12 +629,14 @@ func FloatSlice(key string, fallback []float64) []float64 {
 // stored value is a slice, but not one of booleans, it will attempt to convert
 // or parse the values. If this fails for any item, the fallback is returned.
 // Also returns the fallback when the lookup fails.
+//
+// A string value is split into items first (see SetListSeparator).
 func BoolSlice(key string, fallback []bool) []bool {
 	a, ok := config.get(key)
 	if !ok {
 		return fallback
 	}
-	out, ok := toSlice(a, toBool)
+	out, ok := toList(a, toBool)
 	if !ok {
 		return fallback
 	}
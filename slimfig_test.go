@@ -2,15 +2,25 @@ package slimfig_test
 
 import (
 	"context"
+	stdjson "encoding/json"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/require"
 
 	"github.com/HayoVanLoon/go-slimfig"
 	"github.com/HayoVanLoon/go-slimfig/resolver"
+	"github.com/HayoVanLoon/go-slimfig/resolver/fake"
+	secret "github.com/HayoVanLoon/go-slimfig/resolver/gcp/secret"
+	"github.com/HayoVanLoon/go-slimfig/resolver/json"
+	"github.com/HayoVanLoon/go-slimfig/resolver/yaml"
 )
 
 func Test_Load(t *testing.T) {
@@ -115,6 +125,162 @@ func Test_Load(t *testing.T) {
 				require.NoError,
 			},
 		},
+		{
+			"select subtree and mount",
+			fields{
+				resolvers: []resolver.Resolver{
+					TestResolver{
+						matchOn: "ref1",
+						data: map[string]any{
+							"services": map[string]any{
+								"billing": map[string]any{"host": "x", "port": 1},
+								"mailing": map[string]any{"host": "y"},
+							},
+						},
+					},
+					TestResolver{
+						matchOn: "ref2",
+						data:    map[string]any{"host": "z", "user": "u"},
+					},
+				},
+			},
+			args{references: []string{
+				"ref1#services.billing",
+				"ref1#services.mailing.host@mailing.host",
+				"ref2#@db",
+			}},
+			want{
+				map[string]any{
+					"host":    "x",
+					"port":    1,
+					"mailing": map[string]any{"host": "y"},
+					"db":      map[string]any{"host": "z", "user": "u"},
+				},
+				require.NoError,
+			},
+		},
+		{
+			"at sign in target",
+			fields{
+				resolvers: []resolver.Resolver{
+					TestResolver{
+						matchOn: "config@v2.json",
+						data:    map[string]any{"a": 1},
+					},
+					TestResolver{
+						matchOn: "aws-secretsmanager://svc@prod",
+						data:    map[string]any{"db": map[string]any{"host": "z"}},
+					},
+				},
+			},
+			args{references: []string{
+				"config@v2.json",
+				"aws-secretsmanager://svc@prod#db@database",
+			}},
+			want{
+				map[string]any{
+					"a":        1,
+					"database": map[string]any{"host": "z"},
+				},
+				require.NoError,
+			},
+		},
+		{
+			"select missing subtree",
+			fields{
+				resolvers: []resolver.Resolver{
+					TestResolver{
+						matchOn: "ref1",
+						data:    map[string]any{"a": 1},
+					},
+				},
+			},
+			args{references: []string{"ref1#b"}},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.Error(t, err)
+					require.Equal(t, "error resolving \"ref1#b\": no value at \"b\"", err.Error())
+				},
+			},
+		},
+		{
+			"select non-map without mount",
+			fields{
+				resolvers: []resolver.Resolver{
+					TestResolver{
+						matchOn: "ref1",
+						data:    map[string]any{"a": 1},
+					},
+				},
+			},
+			args{references: []string{"ref1#a"}},
+			want{
+				map[string]any{},
+				require.Error,
+			},
+		},
+		{
+			"optional reference not found",
+			fields{
+				resolvers: []resolver.Resolver{
+					TestResolver{
+						matchOn: "ref1",
+						data:    map[string]any{"a": 1},
+					},
+					TestResolver{
+						matchOn: "ref2",
+						err:     fmt.Errorf("oh noes: %w", resolver.ErrNotFound),
+					},
+				},
+			},
+			args{references: []string{"ref1", "?ref2"}},
+			want{
+				map[string]any{"a": 1},
+				require.NoError,
+			},
+		},
+		{
+			"optional reference other error",
+			fields{
+				resolvers: []resolver.Resolver{
+					TestResolver{
+						matchOn: "ref1",
+						data:    map[string]any{"a": 1},
+					},
+					TestResolver{
+						matchOn: "ref2",
+						err:     fmt.Errorf("oh noes"),
+					},
+				},
+			},
+			args{references: []string{"ref1", "?ref2"}},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.Error(t, err)
+					require.Equal(t, "error resolving \"?ref2\": oh noes", err.Error())
+				},
+			},
+		},
+		{
+			"required reference not found",
+			fields{
+				resolvers: []resolver.Resolver{
+					TestResolver{
+						matchOn: "ref1",
+						err:     fmt.Errorf("oh noes: %w", resolver.ErrNotFound),
+					},
+				},
+			},
+			args{references: []string{"ref1"}},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorIs(t, err, resolver.ErrNotFound)
+				},
+			},
+		},
 		{
 			"no resolver",
 			fields{
@@ -125,188 +291,1985 @@ func Test_Load(t *testing.T) {
 					},
 				},
 			},
-			args{prefix: prefix},
+			args{prefix: prefix},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.Error(t, err)
+					require.Equal(t, "no resolver for \"ref2\"", err.Error())
+				},
+			},
+		},
+		{
+			"error resolving",
+			fields{
+				configEnv: "ref1,ref2",
+				resolvers: []resolver.Resolver{
+					TestResolver{
+						matchOn: "ref1",
+					},
+					TestResolver{
+						matchOn: "ref2",
+						err:     fmt.Errorf("oh noes"),
+					},
+				},
+			},
+			args{prefix: prefix},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.Error(t, err)
+					require.Equal(t, "error resolving \"ref2\": oh noes", err.Error())
+				},
+			},
+		},
+		{
+			"multiple errors",
+			fields{
+				configEnv: "ref1,ref2,ref3",
+				resolvers: []resolver.Resolver{
+					TestResolver{
+						matchOn: "ref1",
+						err:     fmt.Errorf("oh noes"),
+					},
+					TestResolver{
+						matchOn: "ref2",
+					},
+					TestResolver{
+						matchOn: "ref3",
+						err:     fmt.Errorf("not again"),
+					},
+				},
+			},
+			args{prefix: prefix},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.Error(t, err)
+					require.Equal(t, "error resolving \"ref1\": oh noes\nerror resolving \"ref3\": not again", err.Error())
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			ctx := context.Background()
+			setEnvs(tt.fields.envs)
+			if tt.args.prefix != "" && tt.fields.configEnv != "" {
+				setEnv(tt.args.prefix+"_"+slimfig.EnvSuffix, tt.fields.configEnv)
+			}
+			slimfig.SetResolvers(tt.fields.resolvers...)
+
+			err := slimfig.Load(ctx, tt.args.prefix, tt.args.references...)
+			tt.want.err(t, err)
+			actual := slimfig.Config()
+			require.Equal(t, tt.want.value, actual)
+		}))
+	}
+}
+
+func TestLoad_concurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		err         require.ErrorAssertionFunc
+	}{
+		{"concurrent", 3, require.NoError},
+		{"sequential", 1, require.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			// each resolver blocks until all three have started
+			var wg sync.WaitGroup
+			wg.Add(3)
+			started := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(started)
+			}()
+			var rs []resolver.Resolver
+			for i := 1; i <= 3; i += 1 {
+				rs = append(rs, BlockingResolver{
+					TestResolver: TestResolver{
+						matchOn: fmt.Sprintf("ref%d", i),
+						data:    map[string]any{"a": i, fmt.Sprintf("k%d", i): i},
+					},
+					wg:      &wg,
+					started: started,
+				})
+			}
+			slimfig.SetResolvers(rs...)
+			slimfig.SetConcurrency(tt.concurrency)
+
+			err := slimfig.Load(ctx, "", "ref1", "ref2", "ref3")
+			tt.err(t, err)
+			if err == nil {
+				expected := map[string]any{"a": 3, "k1": 1, "k2": 2, "k3": 3}
+				require.Equal(t, expected, slimfig.Config())
+			}
+		}))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type want struct {
+		reports []slimfig.ReferenceReport
+		errs    []string
+	}
+	tests := []struct {
+		name       string
+		resolvers  []resolver.Resolver
+		references []string
+		want       want
+	}{
+		{
+			"happy",
+			[]resolver.Resolver{
+				TestResolver{
+					matchOn: "ref1",
+					data:    map[string]any{"a": 1, "b": map[string]any{"c": "x", "d": "y"}},
+				},
+				TestResolver{
+					matchOn: "ref2",
+					err:     fmt.Errorf("oh noes: %w", resolver.ErrNotFound),
+				},
+			},
+			[]string{"ref1", "?ref2"},
+			want{
+				[]slimfig.ReferenceReport{
+					{
+						Reference: "ref1",
+						Resolver:  "slimfig_test.TestResolver",
+						Size:      len(`{"a":1,"b":{"c":"x","d":"y"}}`),
+						Keys:      3,
+					},
+					{
+						Reference: "?ref2",
+						Resolver:  "slimfig_test.TestResolver",
+						Skipped:   true,
+					},
+				},
+				nil,
+			},
+		},
+		{
+			"all errors",
+			[]resolver.Resolver{
+				TestResolver{
+					matchOn: "ref1",
+					err:     fmt.Errorf("oh noes"),
+				},
+				TestResolver{
+					matchOn: "ref3",
+					data:    map[string]any{"a": 1},
+				},
+			},
+			[]string{"ref1", "ref2", "ref3"},
+			want{
+				[]slimfig.ReferenceReport{
+					{
+						Reference: "ref1",
+						Resolver:  "slimfig_test.TestResolver",
+					},
+					{
+						Reference: "ref2",
+					},
+					{
+						Reference: "ref3",
+						Resolver:  "slimfig_test.TestResolver",
+						Size:      len(`{"a":1}`),
+						Keys:      1,
+					},
+				},
+				[]string{
+					"error resolving \"ref1\": oh noes",
+					"no resolver for \"ref2\"",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			slimfig.SetResolvers(tt.resolvers...)
+
+			report, err := slimfig.Validate(context.Background(), "", tt.references...)
+			require.Len(t, report.References, len(tt.want.reports))
+			for i := range report.References {
+				actual := report.References[i]
+				actual.Duration = 0
+				actual.Err = nil
+				require.Equal(t, tt.want.reports[i], actual)
+			}
+			if tt.want.errs == nil {
+				require.NoError(t, err)
+				return
+			}
+			var loadErr *slimfig.LoadError
+			require.ErrorAs(t, err, &loadErr)
+			var actual []string
+			for _, e := range loadErr.Errors {
+				actual = append(actual, e.Error())
+			}
+			require.Equal(t, tt.want.errs, actual)
+			require.ErrorIs(t, err, slimfig.ErrNoResolver)
+			require.Empty(t, slimfig.Config())
+		}))
+	}
+}
+
+func TestLoad_discovery(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(dir1, "config.json"), `{"a": 1, "b": 1}`)
+	writeFile(t, filepath.Join(dir2, "config.json"), `{"b": 2}`)
+	writeFile(t, filepath.Join(dir2, "config.yaml"), "c: 3\n")
+	writeFile(t, filepath.Join(dir2, "config.toml"), "d = 4\n")
+	d := slimfig.Discovery{
+		Dirs:       []string{dir1, filepath.Join(dir1, "missing"), dir2},
+		Names:      []string{"config"},
+		Extensions: []string{".json", ".yaml", ".toml"},
+	}
+
+	tests := []struct {
+		name       string
+		configEnv  string
+		references []string
+		want       map[string]any
+		found      []string
+	}{
+		{
+			"discovered",
+			"",
+			nil,
+			map[string]any{"a": float64(1), "b": float64(2), "c": 3},
+			[]string{
+				filepath.Join(dir1, "config.json"),
+				filepath.Join(dir2, "config.json"),
+				filepath.Join(dir2, "config.yaml"),
+			},
+		},
+		{
+			"references take precedence",
+			"",
+			[]string{filepath.Join(dir2, "config.json")},
+			map[string]any{"b": float64(2)},
+			[]string{filepath.Join(dir2, "config.json")},
+		},
+		{
+			"environment takes precedence",
+			filepath.Join(dir1, "config.json"),
+			nil,
+			map[string]any{"a": float64(1), "b": float64(1)},
+			[]string{filepath.Join(dir1, "config.json")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			if tt.configEnv != "" {
+				setEnv(prefix_+slimfig.EnvSuffix, tt.configEnv)
+			}
+			slimfig.SetResolvers(json.Resolver(), yaml.Resolver())
+			slimfig.SetDiscovery(&d)
+
+			err := slimfig.Load(context.Background(), prefix, tt.references...)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, slimfig.Config())
+			var found []string
+			for _, r := range slimfig.Report().References {
+				found = append(found, r.Reference)
+			}
+			require.Equal(t, tt.found, found)
+		}))
+	}
+}
+
+func TestLoad_profiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "a: 1\nb: 1\nc: 1\n")
+	writeFile(t, filepath.Join(dir, "config.prod.yaml"), "b: 2\nc: 2\n")
+	writeFile(t, filepath.Join(dir, "config.eu.yaml"), "c: 3\n")
+	writeFile(t, filepath.Join(dir, "db.json"), `{"host": "x"}`)
+
+	tests := []struct {
+		name       string
+		profile    string
+		references []string
+		want       map[string]any
+		expanded   []string
+	}{
+		{
+			"no profile",
+			"",
+			[]string{filepath.Join(dir, "config.yaml")},
+			map[string]any{"a": 1, "b": 1, "c": 1},
+			[]string{filepath.Join(dir, "config.yaml")},
+		},
+		{
+			"profiles in order",
+			"prod,eu",
+			[]string{filepath.Join(dir, "config.yaml")},
+			map[string]any{"a": 1, "b": 2, "c": 3},
+			[]string{
+				filepath.Join(dir, "config.yaml"),
+				"?" + filepath.Join(dir, "config.prod.yaml"),
+				"?" + filepath.Join(dir, "config.eu.yaml"),
+			},
+		},
+		{
+			"reverse order",
+			"eu,prod",
+			[]string{filepath.Join(dir, "config.yaml")},
+			map[string]any{"a": 1, "b": 2, "c": 2},
+			[]string{
+				filepath.Join(dir, "config.yaml"),
+				"?" + filepath.Join(dir, "config.eu.yaml"),
+				"?" + filepath.Join(dir, "config.prod.yaml"),
+			},
+		},
+		{
+			"missing siblings with modifiers",
+			"prod",
+			[]string{filepath.Join(dir, "db.json") + "#@db"},
+			map[string]any{"db": map[string]any{"host": "x"}},
+			[]string{
+				filepath.Join(dir, "db.json") + "#@db",
+				"?" + filepath.Join(dir, "db.prod.json") + "#@db",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			if tt.profile != "" {
+				setEnv(prefix_+slimfig.EnvProfileSuffix, tt.profile)
+			}
+			slimfig.SetResolvers(json.Resolver(), yaml.Resolver())
+
+			err := slimfig.Load(context.Background(), prefix, tt.references...)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, slimfig.Config())
+			var expanded []string
+			for _, r := range slimfig.Report().References {
+				expanded = append(expanded, r.Reference)
+			}
+			require.Equal(t, tt.expanded, expanded)
+		}))
+	}
+}
+
+func TestLoad_profilesFragment(t *testing.T) {
+	client := &fake.GCPSecretManager{
+		Secrets: map[string][]byte{
+			"projects/1/secrets/api-key/versions/latest": []byte("k"),
+		},
+	}
+	t.Run("resolver parameters", clean(func(t *testing.T) {
+		setEnv(prefix_+slimfig.EnvProfileSuffix, "prod")
+		slimfig.SetResolvers(secret.WithClient(client, stdjson.Unmarshal))
+
+		ref := "gcp-secretmanager://projects/1/secrets/api-key#as=auth.api_key"
+		err := slimfig.Load(context.Background(), prefix, ref)
+		require.NoError(t, err)
+		require.Equal(t, map[string]any{"auth": map[string]any{"api_key": "k"}}, slimfig.Config())
+		require.Len(t, slimfig.Report().References, 1)
+		require.Equal(t, []string{"projects/1/secrets/api-key/versions/latest"}, client.Calls())
+	}))
+	t.Run("file with fragment", clean(func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "platform.json"), `{"svc": {"a": 1}}`)
+		writeFile(t, filepath.Join(dir, "platform.prod.json"), `{"svc": {"a": 2}}`)
+		setEnv(prefix_+slimfig.EnvProfileSuffix, "prod")
+		slimfig.SetResolvers(json.Resolver())
+
+		err := slimfig.Load(context.Background(), prefix, filepath.Join(dir, "platform.json")+"#svc.a@x.y")
+		require.NoError(t, err)
+		require.Equal(t, map[string]any{"x": map[string]any{"y": float64(2)}}, slimfig.Config())
+		require.Equal(t, "?"+filepath.Join(dir, "platform.prod.json")+"#svc.a@x.y", slimfig.Report().References[1].Reference)
+	}))
+}
+
+func TestLoad_include(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o700))
+	writeFile(t, filepath.Join(dir, "config.yaml"), "$include: [sub/common.yaml, 'mem://d']\na: 1\n")
+	writeFile(t, filepath.Join(dir, "sub", "common.yaml"), "$include: [base.json, '?missing.json']\na: 0\nb: 2\n")
+	writeFile(t, filepath.Join(dir, "sub", "base.json"), `{"c": 3, "b": 0}`)
+	writeFile(t, filepath.Join(dir, "cycle.yaml"), "$include: cycle2.yaml\n")
+	writeFile(t, filepath.Join(dir, "cycle2.yaml"), "$include: cycle.yaml\n")
+
+	depth := TestResolver{
+		matchOn: "ref2",
+		data:    map[string]any{slimfig.IncludeKey: "ref2"},
+	}
+
+	type want struct {
+		value map[string]any
+		err   require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name      string
+		reference string
+		want      want
+	}{
+		{
+			"nested and relative",
+			filepath.Join(dir, "config.yaml"),
+			want{
+				map[string]any{"a": 1, "b": 2, "c": float64(3), "d": 4},
+				require.NoError,
+			},
+		},
+		{
+			"cycle",
+			filepath.Join(dir, "cycle.yaml"),
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorContains(t, err, "include cycle")
+				},
+			},
+		},
+		{
+			"self",
+			"ref2",
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorContains(t, err, "include cycle: ref2 -> ref2")
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			slimfig.SetResolvers(
+				json.Resolver(),
+				yaml.Resolver(),
+				TestResolver{matchOn: "mem://d", data: map[string]any{"d": 4}},
+				depth,
+			)
+
+			err := slimfig.Load(context.Background(), "", tt.reference)
+			tt.want.err(t, err)
+			require.Equal(t, tt.want.value, slimfig.Config())
+		}))
+	}
+}
+
+func TestLoad_includeDepth(t *testing.T) {
+	var rs []resolver.Resolver
+	// ref0 includes ref1, which includes ref2, etc.
+	for i := 0; i <= slimfig.MaxIncludeDepth; i += 1 {
+		rs = append(rs, TestResolver{
+			matchOn: fmt.Sprintf("ref%d", i),
+			data:    map[string]any{slimfig.IncludeKey: fmt.Sprintf("ref%d", i+1)},
+		})
+	}
+	t.Run("too deep", clean(func(t *testing.T) {
+		slimfig.SetResolvers(rs...)
+		err := slimfig.Load(context.Background(), "", "ref0")
+		require.ErrorContains(t, err, "maximum include depth")
+	}))
+	t.Run("deep enough", clean(func(t *testing.T) {
+		slimfig.SetResolvers(rs...)
+		err := slimfig.Load(context.Background(), "", "ref1")
+		require.ErrorContains(t, err, fmt.Sprintf("no resolver for included \"ref%d\"", slimfig.MaxIncludeDepth+1))
+	}))
+}
+
+func TestLoad_inline(t *testing.T) {
+	client := &fake.GCPSecretManager{
+		Secrets: map[string][]byte{
+			"projects/1/secrets/pass/versions/latest": []byte("s3cr3t"),
+			"projects/1/secrets/db/versions/latest":   []byte(`{"user": "u", "port": 5432}`),
+		},
+	}
+	type want struct {
+		value map[string]any
+		err   require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name string
+		data map[string]any
+		want want
+	}{
+		{
+			"happy",
+			map[string]any{
+				"password": "ref+gcp-secretmanager://projects/1/secrets/pass",
+				"db": map[string]any{
+					"user":     "ref+gcp-secretmanager://projects/1/secrets/db#user",
+					"port":     "ref+gcp-secretmanager://projects/1/secrets/db#port",
+					"password": "ref+gcp-secretmanager://projects/1/secrets/pass",
+				},
+				"list": []any{"ref+gcp-secretmanager://projects/1/secrets/db#user"},
+				"host": "ref",
+			},
+			want{
+				map[string]any{
+					"password": "s3cr3t",
+					"db": map[string]any{
+						"user":     "u",
+						"port":     float64(5432),
+						"password": "s3cr3t",
+					},
+					"list": []any{"u"},
+					"host": "ref",
+				},
+				require.NoError,
+			},
+		},
+		{
+			"missing path",
+			map[string]any{"a": "ref+gcp-secretmanager://projects/1/secrets/db#nope"},
+			want{map[string]any{}, require.Error},
+		},
+		{
+			"not found",
+			map[string]any{"a": "ref+gcp-secretmanager://projects/1/secrets/nope"},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorIs(t, err, resolver.ErrNotFound)
+				},
+			},
+		},
+		{
+			"interpolated",
+			map[string]any{
+				"db": map[string]any{
+					"user":     "ref+gcp-secretmanager://projects/1/secrets/db#user",
+					"password": "ref+gcp-secretmanager://projects/1/secrets/pass",
+				},
+				"dsn": "pg://${db.user}:${db.password}@h",
+			},
+			want{
+				map[string]any{
+					"db":  map[string]any{"user": "u", "password": "s3cr3t"},
+					"dsn": "pg://u:s3cr3t@h",
+				},
+				require.NoError,
+			},
+		},
+		{
+			"raw not supported",
+			map[string]any{"a": "ref+ref2"},
+			want{map[string]any{}, require.Error},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			*client = fake.GCPSecretManager{Secrets: client.Secrets}
+			slimfig.SetResolvers(
+				secret.WithClient(client, stdjson.Unmarshal),
+				TestResolver{matchOn: "ref1", data: tt.data},
+				TestResolver{matchOn: "ref2", data: map[string]any{}},
+			)
+
+			err := slimfig.Load(context.Background(), "", "ref1")
+			tt.want.err(t, err)
+			require.Equal(t, tt.want.value, slimfig.Config())
+			if err == nil {
+				require.ElementsMatch(t, []string{
+					"projects/1/secrets/pass/versions/latest",
+					"projects/1/secrets/db/versions/latest",
+				}, client.Calls())
+			}
+		}))
+	}
+}
+
+func TestLoad_interpolation(t *testing.T) {
+	t.Run("existing templates", clean(func(t *testing.T) {
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: map[string]any{
+			"tmpl": "echo ${HOME}",
+			"sh":   "${1:-default} ${",
+		}})
+		require.NoError(t, slimfig.Load(context.Background(), "", "ref"))
+		require.Equal(t, map[string]any{"tmpl": "echo ${HOME}", "sh": "default ${"}, slimfig.Config())
+	}))
+
+	t.Run("copies referenced maps", clean(func(t *testing.T) {
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: map[string]any{
+			"b": map[string]any{"x": 1},
+			"a": "${b}",
+		}})
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("b.x", 0, "")
+		require.NoError(t, fs.Parse([]string{"-b.x", "2"}))
+
+		require.NoError(t, slimfig.Load(context.Background(), "", "ref"))
+		require.NoError(t, slimfig.BindFlags(fs))
+		require.Equal(t, map[string]any{
+			"a": map[string]any{"x": 1},
+			"b": map[string]any{"x": 2},
+		}, slimfig.Config())
+	}))
+}
+
+func TestLoad_yamlTags(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "a: {b: 1, c: 2}\nd: [x]\ne: [x]\n")
+	writeFile(t, filepath.Join(dir, "override.yaml"), "a: !replace {f: 3}\nd: !append [y]\ne: !prepend [w]\n")
+
+	t.Run("tags", clean(func(t *testing.T) {
+		slimfig.SetResolvers(yaml.Resolver())
+		err := slimfig.Load(context.Background(), "",
+			filepath.Join(dir, "config.yaml"),
+			filepath.Join(dir, "override.yaml"),
+		)
+		require.NoError(t, err)
+		expected := map[string]any{
+			"a": map[string]any{"f": 3},
+			"d": []any{"x", "y"},
+			"e": []any{"w", "x"},
+		}
+		require.Equal(t, expected, slimfig.Config())
+	}))
+}
+
+func TestLoad_patch(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "base.json"), `{"a": 1, "b": {"c": 2, "d": 3}, "xs": ["x", "y"]}`)
+	writeFile(t, filepath.Join(dir, "ops.json"), `[
+		{"op": "test", "path": "/a", "value": 1},
+		{"op": "replace", "path": "/a", "value": 10},
+		{"op": "remove", "path": "/b/d"},
+		{"op": "add", "path": "/xs/-", "value": "z"},
+		{"op": "add", "path": "/xs/0", "value": "w"},
+		{"op": "move", "from": "/b/c", "path": "/c"},
+		{"op": "copy", "from": "/c", "path": "/e~1f"}
+	]`)
+	writeFile(t, filepath.Join(dir, "merge.patch.json"), `{"a": null, "b": {"d": 4}, "xs": ["only"]}`)
+	writeFile(t, filepath.Join(dir, "fail.json"), `[{"op": "test", "path": "/a", "value": 2}]`)
+	writeFile(t, filepath.Join(dir, "bad.json"), `[{"op": "nope", "path": "/a"}]`)
+
+	type want struct {
+		value map[string]any
+		err   require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name       string
+		references []string
+		want       want
+	}{
+		{
+			"json patch",
+			[]string{filepath.Join(dir, "base.json"), "patch+" + filepath.Join(dir, "ops.json")},
+			want{
+				map[string]any{
+					"a":   float64(10),
+					"b":   map[string]any{},
+					"c":   float64(2),
+					"e/f": float64(2),
+					"xs":  []any{"w", "x", "y", "z"},
+				},
+				require.NoError,
+			},
+		},
+		{
+			"json patch with json scheme",
+			[]string{filepath.Join(dir, "base.json"), "patch+json://" + filepath.Join(dir, "ops.json")},
+			want{
+				map[string]any{
+					"a":   float64(10),
+					"b":   map[string]any{},
+					"c":   float64(2),
+					"e/f": float64(2),
+					"xs":  []any{"w", "x", "y", "z"},
+				},
+				require.NoError,
+			},
+		},
+		{
+			"merge patch by suffix",
+			[]string{filepath.Join(dir, "base.json"), filepath.Join(dir, "merge.patch.json")},
+			want{
+				map[string]any{
+					"b":  map[string]any{"c": float64(2), "d": float64(4)},
+					"xs": []any{"only"},
+				},
+				require.NoError,
+			},
+		},
+		{
+			"applied in order",
+			[]string{
+				"patch+" + filepath.Join(dir, "ops.json"),
+				filepath.Join(dir, "base.json"),
+			},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorContains(t, err, `key "a" not found`)
+				},
+			},
+		},
+		{
+			"test fails",
+			[]string{filepath.Join(dir, "base.json"), "patch+" + filepath.Join(dir, "fail.json")},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					var refErr *slimfig.ReferenceError
+					require.ErrorAs(t, err, &refErr)
+					require.Equal(t, "patch+"+filepath.Join(dir, "fail.json"), refErr.Reference)
+					require.ErrorContains(t, err, "test failed")
+				},
+			},
+		},
+		{
+			"invalid operation",
+			[]string{filepath.Join(dir, "base.json"), "patch+" + filepath.Join(dir, "bad.json")},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorContains(t, err, `unknown op "nope"`)
+				},
+			},
+		},
+		{
+			"optional missing",
+			[]string{filepath.Join(dir, "base.json"), "?patch+" + filepath.Join(dir, "missing.json")},
+			want{
+				map[string]any{"a": float64(1), "b": map[string]any{"c": float64(2), "d": float64(3)}, "xs": []any{"x", "y"}},
+				require.NoError,
+			},
+		},
+		{
+			"no raw resolver",
+			[]string{"patch+ref1"},
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorContains(t, err, "does not support patches")
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			slimfig.SetResolvers(json.Resolver(), TestResolver{matchOn: "ref1"})
+
+			err := slimfig.Load(context.Background(), "", tt.references...)
+			tt.want.err(t, err)
+			require.Equal(t, tt.want.value, slimfig.Config())
+		}))
+	}
+}
+
+func TestLoad_flags(t *testing.T) {
+	resolvers := []resolver.Resolver{
+		TestResolver{matchOn: "ref1", data: map[string]any{"a": 1, "b": "x", "hosts": []any{"h1"}}},
+		TestResolver{matchOn: "ref2", data: map[string]any{"b": "y"}},
+		TestResolver{matchOn: "ref3", data: map[string]any{"c": true}},
+	}
+	type want struct {
+		value map[string]any
+		err   require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name string
+		args []string
+		envs map[string]string
+		want want
+	}{
+		{
+			"no flags",
+			[]string{"-v", "serve"},
+			nil,
+			want{map[string]any{"a": 1, "b": "x", "hosts": []any{"h1"}}, require.NoError},
+		},
+		{
+			"config",
+			[]string{"--config", "ref1,ref2", "-config=ref3"},
+			map[string]string{prefix_ + "CONFIG": "ref3"},
+			want{map[string]any{"a": 1, "b": "y", "c": true, "hosts": []any{"h1"}}, require.NoError},
+		},
+		{
+			"set",
+			[]string{"--set", "a=2", "--set=d.e=[1, 2]", "-set", "hosts.1=h2", "--set", "b=3"},
+			map[string]string{prefix_ + "b": "z"},
+			want{
+				map[string]any{
+					"a":     2,
+					"b":     "3",
+					"d":     map[string]any{"e": []any{1, 2}},
+					"hosts": []any{"h1", "h2"},
+				},
+				require.NoError,
+			},
+		},
+		{
+			"after double dash",
+			[]string{"--", "--set", "a=2"},
+			nil,
+			want{map[string]any{"a": 1, "b": "x", "hosts": []any{"h1"}}, require.NoError},
+		},
+		{
+			"missing value",
+			[]string{"--set"},
+			nil,
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorContains(t, err, "flag needs an argument: --set")
+				},
+			},
+		},
+		{
+			"invalid set",
+			[]string{"--set", "a"},
+			nil,
+			want{
+				map[string]any{},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorContains(t, err, `invalid --set "a"`)
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			setEnvs(tt.envs)
+			slimfig.SetResolvers(resolvers...)
+			slimfig.SetArgs(tt.args)
+
+			err := slimfig.Load(context.Background(), prefix, "ref1")
+			tt.want.err(t, err)
+			require.Equal(t, tt.want.value, slimfig.Config())
+		}))
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	t.Run("happy", clean(func(t *testing.T) {
+		slimfig.SetResolvers(
+			TestResolver{matchOn: "ref1", data: map[string]any{"a": 1}},
+			TestResolver{matchOn: "ref2", data: map[string]any{"b": 2}},
+		)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		verbose := fs.Bool("v", false, "verbose")
+		slimfig.RegisterFlags(fs)
+		require.NoError(t, fs.Parse([]string{"-v", "--config", "ref1", "--config", "ref2", "--set", "a=3"}))
+
+		err := slimfig.Load(context.Background(), "")
+		require.NoError(t, err)
+		require.True(t, *verbose)
+		require.Equal(t, map[string]any{"a": 3, "b": 2}, slimfig.Config())
+	}))
+}
+
+func TestBindFlags(t *testing.T) {
+	data := map[string]any{
+		"port":      8080,
+		"host":      "localhost",
+		"max_conns": 10,
+		"tags":      []any{"a", "b"},
+		"db":        map[string]any{"name": "main"},
+	}
+
+	t.Run("flag", clean(func(t *testing.T) {
+		setEnvs(map[string]string{prefix_ + "host": "env"})
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: copyMap(data)})
+		slimfig.SetArgs([]string{"--set", "max_conns=20"})
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		port := fs.Int("port", 1, "")
+		host := fs.String("host", "default", "")
+		maxConns := fs.Int("max-conns", 1, "")
+		dbName := fs.String("db.name", "", "")
+		debug := fs.Bool("debug", false, "")
+		require.NoError(t, fs.Parse([]string{"-port", "9090"}))
+
+		require.NoError(t, slimfig.Load(context.Background(), prefix, "ref"))
+		require.NoError(t, slimfig.BindFlags(fs))
+
+		require.Equal(t, 9090, *port)
+		require.Equal(t, "env", *host)
+		require.Equal(t, 20, *maxConns)
+		require.Equal(t, "main", *dbName)
+		require.False(t, *debug)
+		require.Equal(t, "env", fs.Lookup("host").DefValue)
+		require.Equal(t, 9090, slimfig.Int("port", 0))
+		require.Equal(t, 20, slimfig.Int("max_conns", 0))
+		require.Equal(t, false, slimfig.Bool("debug", true))
+	}))
+
+	t.Run("pflag", clean(func(t *testing.T) {
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: copyMap(data)})
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		port := fs.Int("port", 1, "")
+		tags := fs.StringSlice("tags", nil, "")
+		dbName := fs.String("db-name", "", "")
+		require.NoError(t, fs.Parse([]string{"--db-name", "other"}))
+		slimfig.SetFlagMapper(func(name string) string {
+			return strings.ReplaceAll(name, "-", ".")
+		})
+
+		require.NoError(t, slimfig.Load(context.Background(), "", "ref"))
+		require.NoError(t, slimfig.BindFlags(fs))
+
+		require.Equal(t, 8080, *port)
+		require.Equal(t, []string{"a", "b"}, *tags)
+		require.Equal(t, "other", *dbName)
+		require.Equal(t, "other", slimfig.String("db.name", ""))
+	}))
+
+	t.Run("invalid configuration value", clean(func(t *testing.T) {
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: map[string]any{"port": "nope"}})
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("port", 1, "")
+		require.NoError(t, fs.Parse(nil))
+
+		require.NoError(t, slimfig.Load(context.Background(), "", "ref"))
+		err := slimfig.BindFlags(fs)
+		require.ErrorContains(t, err, `error binding flag "port" to "port"`)
+	}))
+
+	t.Run("nothing changed on error", clean(func(t *testing.T) {
+		data := map[string]any{"a": "x", "port": "nope", "tags": []any{"x", "y"}}
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: copyMap(data)})
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		a := fs.String("a", "default", "")
+		debug := fs.Bool("debug", false, "")
+		port := fs.Int("port", 1, "")
+		tags := fs.StringSlice("tags", []string{"z"}, "")
+		require.NoError(t, fs.Parse([]string{"--debug"}))
+
+		require.NoError(t, slimfig.Load(context.Background(), "", "ref"))
+		require.Error(t, slimfig.BindFlags(fs))
+		require.Equal(t, data, slimfig.Config())
+		require.Equal(t, "default", *a)
+		require.Equal(t, "default", fs.Lookup("a").DefValue)
+		require.True(t, *debug)
+		require.Equal(t, 1, *port)
+		require.Equal(t, []string{"z"}, *tags)
+	}))
+
+	t.Run("large numbers", clean(func(t *testing.T) {
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: map[string]any{
+			"max_conns": float64(1000000),
+			"ratio":     float64(0.0000001),
+			"sizes":     []any{float64(2000000), float64(3)},
+		}})
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		maxConns := fs.Int("max-conns", 1, "")
+		ratio := fs.Float64("ratio", 1, "")
+		sizes := fs.IntSlice("sizes", nil, "")
+		require.NoError(t, fs.Parse(nil))
+
+		require.NoError(t, slimfig.Load(context.Background(), "", "ref"))
+		require.NoError(t, slimfig.BindFlags(fs))
+		require.Equal(t, 1000000, *maxConns)
+		require.Equal(t, 0.0000001, *ratio)
+		require.Equal(t, []int{2000000, 3}, *sizes)
+	}))
+}
+
+func TestDefine(t *testing.T) {
+	data := map[string]any{
+		"timeout": "1m",
+		"db":      map[string]any{"host": "db.local", "password": "s3cr3t"},
+		"tags":    "a,b",
+	}
+
+	t.Run("getters", clean(func(t *testing.T) {
+		timeout := slimfig.Define[time.Duration]("timeout", 30*time.Second, "request timeout")
+		retries := slimfig.Define("retries", 3, "number of retries")
+		host := slimfig.Define("db.host", "localhost", "database host")
+		tags := slimfig.Define[[]string]("tags", nil, "tags")
+
+		require.Equal(t, 30*time.Second, timeout.Get())
+		require.Equal(t, "localhost", host.Get())
+
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: copyMap(data)})
+		require.NoError(t, slimfig.Load(context.Background(), "", "ref"))
+		require.Equal(t, time.Minute, timeout.Get())
+		require.Equal(t, 3, retries.Get())
+		require.Equal(t, "db.local", host.Get())
+		require.Equal(t, []string{"a", "b"}, tags.Get())
+	}))
+
+	t.Run("env and sensitivity", clean(func(t *testing.T) {
+		setEnv(prefix_+"DB_PASSWORD", "env-s3cr3t")
+		password := slimfig.Define("db.password", "", "database password",
+			slimfig.Env(prefix_+"DB_PASSWORD"), slimfig.Sensitive())
+		slimfig.Define("timeout", time.Second, "request timeout")
+
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: copyMap(data)})
+		require.NoError(t, slimfig.Load(context.Background(), "", "ref"))
+		require.Equal(t, "env-s3cr3t", password.Get())
+		require.Equal(t, "db.password=REDACTED", password.String())
+		require.Equal(t, []slimfig.Definition{
+			{
+				Key:         "db.password",
+				Type:        "string",
+				Description: "database password",
+				Env:         prefix_ + "DB_PASSWORD",
+				Sensitive:   true,
+			},
+			{
+				Key:         "timeout",
+				Type:        "time.Duration",
+				Default:     time.Second,
+				Description: "request timeout",
+			},
+		}, slimfig.Definitions())
+	}))
+
+	t.Run("required", clean(func(t *testing.T) {
+		slimfig.Define("db.host", "", "database host", slimfig.Required())
+		slimfig.Define("db.port", 0, "database port", slimfig.Required())
+		slimfig.Define("api_key", "", "API key", slimfig.Required())
+
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: copyMap(data)})
+		err := slimfig.Load(context.Background(), "", "ref")
+		require.ErrorIs(t, err, slimfig.ErrMissingKey)
+		require.EqualError(t, err, "missing required key: api_key, db.port")
+		require.Equal(t, map[string]any{}, slimfig.Config())
+	}))
+
+	t.Run("strict", clean(func(t *testing.T) {
+		setEnv(prefix_+"db__port", "5432")
+		setEnv(prefix_+"db__prot", "5432")
+		slimfig.Define("db.port", 0, "database port")
+		slimfig.SetEnvPolicy(&slimfig.EnvPolicy{Strict: true})
+
+		slimfig.SetResolvers(TestResolver{matchOn: "ref", data: copyMap(data)})
+		require.NoError(t, slimfig.Load(context.Background(), prefix, "ref"))
+		require.Equal(t, "5432", slimfig.String("db.port", ""))
+		skipped := slimfig.Report().SkippedEnv
+		require.Len(t, skipped, 1)
+		require.Equal(t, []string{"db.port"}, skipped[0].Suggestions)
+	}))
+
+	t.Run("defined twice", clean(func(t *testing.T) {
+		slimfig.Define("a", 1, "")
+		require.Panics(t, func() {
+			slimfig.Define("a", "", "")
+		})
+	}))
+}
+
+func TestDefaultDiscovery(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	d := slimfig.DefaultDiscovery("app")
+	require.Equal(t, []string{"/etc/app", "/xdg/app", "."}, d.Dirs)
+	require.Equal(t, []string{"config"}, d.Names)
+	require.Equal(t, []string{".json", ".yaml", ".toml"}, d.Extensions)
+}
+
+func Test_merge(t *testing.T) {
+	type args struct {
+		old map[string]any
+		v   map[string]any
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]any
+	}{
+		{
+			"add simple value",
+			args{
+				map[string]any{"a": 1, "c": map[string]any{"d": 3, "e": 4}},
+				map[string]any{"b": 2},
+			},
+			map[string]any{"a": 1, "b": 2, "c": map[string]any{"d": 3, "e": 4}},
+		},
+		{
+			"add too empty map",
+			args{
+				map[string]any{},
+				map[string]any{"b": 2},
+			},
+			map[string]any{"b": 2},
+		},
+		{
+			"replace simple value",
+			args{
+				map[string]any{"a": 1, "c": map[string]any{"d": 3, "e": 4}},
+				map[string]any{"a": "x"},
+			},
+			map[string]any{"a": "x", "c": map[string]any{"d": 3, "e": 4}},
+		},
+		{
+			"add map",
+			args{
+				map[string]any{"a": 1},
+				map[string]any{"c": map[string]any{"e": 4}},
+			},
+			map[string]any{"a": 1, "c": map[string]any{"e": 4}},
+		},
+		{
+			"add values at different levels",
+			args{
+				map[string]any{"c": map[string]any{"d": 3}},
+				map[string]any{"b": 2, "c": map[string]any{"e": 4}},
+			},
+			map[string]any{"b": 2, "c": map[string]any{"d": 3, "e": 4}},
+		},
+		{
+			"replace map with non-map",
+			args{
+				map[string]any{"b": 2, "c": map[string]any{"f": map[string]any{"g": "6"}}},
+				map[string]any{"c": map[string]any{"f": "x"}},
+			},
+			map[string]any{"b": 2, "c": map[string]any{"f": "x"}},
+		},
+		{
+			"replace non-map with map",
+			args{
+				map[string]any{"a": 1, "b": 2, "c": map[string]any{"d": 4}},
+				map[string]any{"b": map[string]any{"f": "x"}},
+			},
+			map[string]any{
+				"a": 1,
+				"b": map[string]any{"f": "x"},
+				"c": map[string]any{"d": 4},
+			},
+		},
+		{
+			"handle maps with non-string keys",
+			args{
+				map[string]any{
+					"int": map[int]int{10: 1},
+				},
+				map[string]any{
+					"int": map[string]any{"20": "42"},
+				},
+			},
+			map[string]any{
+				"int": map[string]any{"10": 1, "20": "42"},
+			},
+		},
+		{
+			"null deletes",
+			args{
+				map[string]any{"a": 1, "b": 2, "c": map[string]any{"d": 3, "e": 4}},
+				map[string]any{"a": nil, "c": map[string]any{"d": nil}},
+			},
+			map[string]any{"b": 2, "c": map[string]any{"e": 4}},
+		},
+		{
+			"null for new key is kept",
+			args{
+				map[string]any{},
+				map[string]any{"a": nil, "c": map[string]any{"d": nil}},
+			},
+			map[string]any{"a": nil, "c": map[string]any{"d": nil}},
+		},
+		{
+			"replace map",
+			args{
+				map[string]any{"c": map[string]any{"d": 3, "e": 4}},
+				map[string]any{"c": map[string]any{"$replace": true, "f": 5}},
+			},
+			map[string]any{"c": map[string]any{"f": 5}},
+		},
+		{
+			"replace false merges",
+			args{
+				map[string]any{"c": map[string]any{"d": 3}},
+				map[string]any{"c": map[string]any{"$replace": false, "f": 5}},
+			},
+			map[string]any{"c": map[string]any{"d": 3, "f": 5}},
+		},
+		{
+			"append and prepend",
+			args{
+				map[string]any{"a": []any{"x"}, "b": []string{"x"}, "c": []any{"x"}},
+				map[string]any{
+					"a": map[string]any{"$append": []any{"y", "z"}},
+					"b": map[string]any{"$prepend": []any{"w"}},
+					"c": map[string]any{"$prepend": []any{"w"}, "$append": []any{"y"}},
+				},
+			},
+			map[string]any{
+				"a": []any{"x", "y", "z"},
+				"b": []any{"w", "x"},
+				"c": []any{"w", "x", "y"},
+			},
+		},
+		{
+			"directives on new keys",
+			args{
+				map[string]any{},
+				map[string]any{
+					"a": map[string]any{"$append": []any{"y"}},
+					"b": map[string]any{"$replace": true, "c": nil, "d": map[string]any{"$prepend": []any{1}}},
+				},
+			},
+			map[string]any{
+				"a": []any{"y"},
+				"b": map[string]any{"c": nil, "d": []any{1}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			actual := &tt.args.old
+			slimfig.Merge(actual, tt.args.v)
+			require.Equal(t, tt.want, *actual)
+		}))
+	}
+}
+
+func Test_merge_keyed(t *testing.T) {
+	upstreams := func() []any {
+		return []any{
+			map[string]any{"name": "a", "host": "a.example.com", "timeout_s": 10},
+			map[string]any{"name": "b", "host": "b.example.com", "timeout_s": 10},
+		}
+	}
+	type args struct {
+		mergeKeys map[string]string
+		old       map[string]any
+		v         map[string]any
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]any
+	}{
+		{
+			"marker",
+			args{
+				nil,
+				map[string]any{"upstreams": upstreams()},
+				map[string]any{"upstreams": map[string]any{
+					"$mergeKey": "name",
+					"$items": []any{
+						map[string]any{"name": "b", "timeout_s": 5, "host": nil},
+						map[string]any{"name": "c", "host": "c.example.com"},
+					},
+				}},
+			},
+			map[string]any{"upstreams": []any{
+				map[string]any{"name": "a", "host": "a.example.com", "timeout_s": 10},
+				map[string]any{"name": "b", "timeout_s": 5},
+				map[string]any{"name": "c", "host": "c.example.com"},
+			}},
+		},
+		{
+			"marker on new key",
+			args{
+				nil,
+				map[string]any{},
+				map[string]any{"upstreams": map[string]any{
+					"$mergeKey": "name",
+					"$items":    []any{map[string]any{"name": "c"}},
+				}},
+			},
+			map[string]any{"upstreams": []any{map[string]any{"name": "c"}}},
+		},
+		{
+			"declared path",
+			args{
+				map[string]string{"gateway.upstreams": "name"},
+				map[string]any{"gateway": map[string]any{"upstreams": upstreams()}},
+				map[string]any{"gateway": map[string]any{"upstreams": []any{
+					map[string]any{"name": "a", "timeout_s": 5},
+				}}},
+			},
+			map[string]any{"gateway": map[string]any{"upstreams": []any{
+				map[string]any{"name": "a", "host": "a.example.com", "timeout_s": 5},
+				map[string]any{"name": "b", "host": "b.example.com", "timeout_s": 10},
+			}}},
+		},
+		{
+			"undeclared path replaces",
+			args{
+				map[string]string{"upstreams": "name"},
+				map[string]any{"gateway": map[string]any{"upstreams": upstreams()}},
+				map[string]any{"gateway": map[string]any{"upstreams": []any{
+					map[string]any{"name": "a", "timeout_s": 5},
+				}}},
+			},
+			map[string]any{"gateway": map[string]any{"upstreams": []any{
+				map[string]any{"name": "a", "timeout_s": 5},
+			}}},
+		},
+		{
+			"marker without items",
+			args{
+				nil,
+				map[string]any{"upstreams": upstreams()},
+				map[string]any{"upstreams": map[string]any{"$mergeKey": "name"}},
+			},
+			map[string]any{"upstreams": upstreams()},
+		},
+		{
+			"marker without items on new key",
+			args{
+				nil,
+				map[string]any{},
+				map[string]any{"upstreams": map[string]any{"$mergeKey": "name"}},
+			},
+			map[string]any{"upstreams": []any{}},
+		},
+		{
+			"nested declared path",
+			args{
+				map[string]string{"upstreams": "name", "upstreams.endpoints": "url"},
+				map[string]any{"upstreams": []any{
+					map[string]any{"name": "a", "endpoints": []any{
+						map[string]any{"url": "/x", "weight": 1},
+						map[string]any{"url": "/y", "weight": 1},
+					}},
+				}},
+				map[string]any{"upstreams": []any{
+					map[string]any{"name": "a", "endpoints": []any{
+						map[string]any{"url": "/y", "weight": 2},
+					}},
+				}},
+			},
+			map[string]any{"upstreams": []any{
+				map[string]any{"name": "a", "endpoints": []any{
+					map[string]any{"url": "/x", "weight": 1},
+					map[string]any{"url": "/y", "weight": 2},
+				}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			slimfig.SetMergeKeys(tt.args.mergeKeys)
+			actual := &tt.args.old
+			slimfig.Merge(actual, tt.args.v)
+			require.Equal(t, tt.want, *actual)
+		}))
+	}
+}
+
+func Test_interpolate(t *testing.T) {
+	type want struct {
+		value map[string]any
+		err   require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name string
+		envs map[string]string
+		m    map[string]any
+		want want
+	}{
+		{
+			"no references",
+			nil,
+			map[string]any{"a": "x", "b": 1},
+			want{map[string]any{"a": "x", "b": 1}, require.NoError},
+		},
+		{
+			"environment",
+			map[string]string{prefix_ + "HOST": "example.com"},
+			map[string]any{"a": "https://${env:" + prefix_ + "HOST}/"},
+			want{map[string]any{"a": "https://example.com/"}, require.NoError},
+		},
+		{
+			"environment default",
+			nil,
+			map[string]any{"a": "${env:" + prefix_ + "HOST:-localhost}:8080"},
+			want{map[string]any{"a": "localhost:8080"}, require.NoError},
+		},
+		{
+			"environment missing",
+			nil,
+			map[string]any{"a": "${env:" + prefix_ + "HOST}"},
+			want{nil, require.Error},
+		},
+		{
+			"keys",
+			nil,
+			map[string]any{
+				"host": "example.com",
+				"a":    map[string]any{"url": "https://${host}:${port:-443}/${b.path}"},
+				"b":    map[string]any{"path": "${c}"},
+				"c":    "api",
+			},
+			want{
+				map[string]any{
+					"host": "example.com",
+					"a":    map[string]any{"url": "https://example.com:443/api"},
+					"b":    map[string]any{"path": "api"},
+					"c":    "api",
+				},
+				require.NoError,
+			},
+		},
+		{
+			"typed",
+			nil,
+			map[string]any{
+				"port":    8080,
+				"hosts":   []any{"a", "${c}"},
+				"c":       "b",
+				"x":       "${port}",
+				"y":       "${hosts}",
+				"z":       "${hosts.1}",
+				"missing": "${nope:-1}",
+			},
+			want{
+				map[string]any{
+					"port":    8080,
+					"hosts":   []any{"a", "b"},
+					"c":       "b",
+					"x":       8080,
+					"y":       []any{"a", "b"},
+					"z":       "b",
+					"missing": "1",
+				},
+				require.NoError,
+			},
+		},
+		{
+			"escaped",
+			nil,
+			map[string]any{"a": "$${b}", "b": "x$${b}y", "c": "${a}"},
+			want{map[string]any{"a": "${b}", "b": "x${b}y", "c": "${b}"}, require.NoError},
+		},
+		{
+			"missing key",
+			nil,
+			map[string]any{"a": "${b}", "c": "echo ${HOME} ${d}", "d": 1},
+			want{map[string]any{"a": "${b}", "c": "echo ${HOME} 1", "d": 1}, require.NoError},
+		},
+		{
+			"cycle",
+			nil,
+			map[string]any{"a": "${b}", "b": "x${c}", "c": map[string]any{"d": "${a}"}},
+			want{
+				nil,
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorContains(t, err, "interpolation cycle")
+				},
+			},
+		},
+		{
+			"unterminated",
+			nil,
+			map[string]any{"a": "${b", "b": 1, "c": "${b}${b"},
+			want{map[string]any{"a": "${b", "b": 1, "c": "1${b"}, require.NoError},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			setEnvs(tt.envs)
+			err := slimfig.Interpolate(tt.m)
+			tt.want.err(t, err)
+			if tt.want.value != nil {
+				require.Equal(t, tt.want.value, tt.m)
+			}
+		}))
+	}
+}
+
+func Test_loadEnvironment(t *testing.T) {
+	type fields struct {
+		config map[string]any
+		envs   map[string]string
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   map[string]any
+	}{
+		{
+			"happy",
+			fields{
+				map[string]any{
+					"a": 1,
+					"b": 2,
+				},
+				map[string]string{
+					prefix_ + "foo": "123",
+					prefix_ + "BAR": "456",
+				},
+			},
+			map[string]any{
+				"a":   1,
+				"b":   2,
+				"foo": "123",
+				"BAR": "456",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			setEnvs(tt.fields.envs)
+			slimfig.SetConfig(tt.fields.config)
+
+			slimfig.LoadEnvironment(prefix)
+			actual := slimfig.Config()
+			require.Equal(t, tt.want, actual)
+		}))
+	}
+}
+
+func TestEnvMapper(t *testing.T) {
+	cfg := map[string]any{
+		"timeout_s": 30,
+		"db": map[string]any{
+			"max_conns": 10,
+			"Host":      "localhost",
+		},
+		"log":   map[string]any{"level": "info"},
+		"hosts": []any{map[string]any{"name": "a"}},
+	}
+	tests := []struct {
+		name   string
+		mapper slimfig.EnvMapper
+		env    string
+		want   []string
+	}{
+		{"default", slimfig.DefaultEnvMapper, "db__Host", []string{"db", "Host"}},
+		{"default preserves case", slimfig.DefaultEnvMapper, "DB__HOST", []string{"DB", "HOST"}},
+		{"separator", slimfig.SeparatorMapper("."), "db.Host", []string{"db", "Host"}},
+		{"lowercase", slimfig.LowercaseMapper(slimfig.DefaultEnvMapper), "DB__HOST", []string{"db", "host"}},
+		{
+			"case-insensitive",
+			slimfig.CaseInsensitiveMapper(slimfig.DefaultEnvMapper),
+			"DB__HOST",
+			[]string{"db", "Host"},
+		},
+		{
+			"case-insensitive new key",
+			slimfig.CaseInsensitiveMapper(slimfig.DefaultEnvMapper),
+			"DB__PORT",
+			[]string{"db", "PORT"},
+		},
+		{"key tree", slimfig.KeyTreeMapper("_"), "TIMEOUT_S", []string{"timeout_s"}},
+		{"key tree nested", slimfig.KeyTreeMapper("_"), "DB_MAX_CONNS", []string{"db", "max_conns"}},
+		{"key tree new key", slimfig.KeyTreeMapper("_"), "LOG_FILE_NAME", []string{"log", "FILE_NAME"}},
+		{"key tree unknown", slimfig.KeyTreeMapper("_"), "NEW_KEY", []string{"NEW_KEY"}},
+		{"key tree list", slimfig.KeyTreeMapper("_"), "HOSTS_0_NAME", []string{"hosts", "0", "name"}},
+		{"key tree separator", slimfig.KeyTreeMapper("-"), "DB-MAX_CONNS", []string{"db", "max_conns"}},
+		{"key tree composed", slimfig.LowercaseMapper(slimfig.KeyTreeMapper("_")), "DB_MAX_CONNS", []string{"db", "max_conns"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.mapper(tt.env, cfg))
+		})
+	}
+}
+
+func TestLoad_envPolicy(t *testing.T) {
+	data := map[string]any{
+		"auth":    map[string]any{"issuer": "a", "audience": "b"},
+		"db":      map[string]any{"main_url": "x", "timeout_s": 1},
+		"log":     "info",
+		"timeout": 1,
+	}
+	envs := map[string]string{
+		prefix_ + "auth__issuer":  "evil",
+		prefix_ + "db__main_url":  "y",
+		prefix_ + "db__timeout_s": "2",
+		prefix_ + "log":           "debug",
+	}
+	type want struct {
+		value   map[string]any
+		skipped []string
+		err     require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name   string
+		policy *slimfig.EnvPolicy
+		want   want
+	}{
+		{
+			"no policy",
+			nil,
+			want{
+				map[string]any{
+					"auth":    map[string]any{"issuer": "evil", "audience": "b"},
+					"db":      map[string]any{"main_url": "y", "timeout_s": "2"},
+					"log":     "debug",
+					"timeout": 1,
+				},
+				nil,
+				require.NoError,
+			},
+		},
+		{
+			"deny",
+			&slimfig.EnvPolicy{Deny: []string{"auth"}},
 			want{
-				map[string]any{},
-				func(t require.TestingT, err error, _ ...interface{}) {
-					require.Error(t, err)
-					require.Equal(t, "no resolver for \"ref2\"", err.Error())
+				map[string]any{
+					"auth":    map[string]any{"issuer": "a", "audience": "b"},
+					"db":      map[string]any{"main_url": "y", "timeout_s": "2"},
+					"log":     "debug",
+					"timeout": 1,
 				},
+				[]string{prefix_ + "auth__issuer"},
+				require.NoError,
 			},
 		},
 		{
-			"error resolving",
-			fields{
-				configEnv: "ref1,ref2",
-				resolvers: []resolver.Resolver{
-					TestResolver{
-						matchOn: "ref1",
-					},
-					TestResolver{
-						matchOn: "ref2",
-						err:     fmt.Errorf("oh noes"),
-					},
+			"allow with glob",
+			&slimfig.EnvPolicy{Allow: []string{"db.*_url", "log"}},
+			want{
+				map[string]any{
+					"auth":    map[string]any{"issuer": "a", "audience": "b"},
+					"db":      map[string]any{"main_url": "y", "timeout_s": 1},
+					"log":     "debug",
+					"timeout": 1,
+				},
+				[]string{prefix_ + "auth__issuer", prefix_ + "db__timeout_s"},
+				require.NoError,
+			},
+		},
+		{
+			"deny takes precedence",
+			&slimfig.EnvPolicy{Allow: []string{"*"}, Deny: []string{"*.issuer"}},
+			want{
+				map[string]any{
+					"auth":    map[string]any{"issuer": "a", "audience": "b"},
+					"db":      map[string]any{"main_url": "y", "timeout_s": "2"},
+					"log":     "debug",
+					"timeout": 1,
 				},
+				[]string{prefix_ + "auth__issuer"},
+				require.NoError,
 			},
-			args{prefix: prefix},
+		},
+		{
+			"reject",
+			&slimfig.EnvPolicy{Deny: []string{"auth.issuer"}, Reject: true},
 			want{
 				map[string]any{},
+				[]string{prefix_ + "auth__issuer"},
 				func(t require.TestingT, err error, _ ...interface{}) {
-					require.Error(t, err)
-					require.Equal(t, "error resolving \"ref2\": oh noes", err.Error())
+					require.ErrorIs(t, err, slimfig.ErrEnvNotAllowed)
+					var envErr *slimfig.EnvError
+					require.ErrorAs(t, err, &envErr)
+					require.Equal(t, "auth.issuer", envErr.Key)
 				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, clean(func(t *testing.T) {
-			ctx := context.Background()
-			setEnvs(tt.fields.envs)
-			if tt.args.prefix != "" && tt.fields.configEnv != "" {
-				setEnv(tt.args.prefix+"_"+slimfig.EnvSuffix, tt.fields.configEnv)
-			}
-			slimfig.SetResolvers(tt.fields.resolvers...)
+			setEnvs(envs)
+			setEnv("OTHER_"+prefix_+"timeout", "2")
+			defer os.Unsetenv("OTHER_" + prefix_ + "timeout")
+			slimfig.SetResolvers(TestResolver{matchOn: "ref", data: data})
+			slimfig.SetEnvPolicy(tt.policy)
 
-			err := slimfig.Load(ctx, tt.args.prefix, tt.args.references...)
+			err := slimfig.Load(context.Background(), prefix, "ref")
 			tt.want.err(t, err)
-			actual := slimfig.Config()
-			require.Equal(t, tt.want.value, actual)
+			require.Equal(t, tt.want.value, slimfig.Config())
+			var skipped []string
+			for _, e := range slimfig.Report().SkippedEnv {
+				skipped = append(skipped, e.Variable)
+			}
+			require.Equal(t, tt.want.skipped, skipped)
 		}))
 	}
 }
 
-func Test_merge(t *testing.T) {
-	type args struct {
-		old map[string]any
-		v   map[string]any
+func TestLoad_envPolicyParent(t *testing.T) {
+	data := map[string]any{
+		"auth": map[string]any{"issuer": "a", "audience": "b"},
+	}
+	type want struct {
+		value   map[string]any
+		skipped []string
 	}
 	tests := []struct {
-		name string
-		args args
-		want map[string]any
+		name   string
+		env    string
+		typing slimfig.EnvTyping
+		policy *slimfig.EnvPolicy
+		want   want
 	}{
 		{
-			"add simple value",
-			args{
-				map[string]any{"a": 1, "c": map[string]any{"d": 3, "e": 4}},
-				map[string]any{"b": 2},
-			},
-			map[string]any{"a": 1, "b": 2, "c": map[string]any{"d": 3, "e": 4}},
+			"deny child with parsed parent",
+			`{"issuer": "evil"}`,
+			slimfig.EnvParse,
+			&slimfig.EnvPolicy{Deny: []string{"auth.issuer"}},
+			want{data, []string{prefix_ + "auth"}},
 		},
 		{
-			"add too empty map",
-			args{
-				map[string]any{},
-				map[string]any{"b": 2},
-			},
-			map[string]any{"b": 2},
+			"deny child with string parent",
+			`{"issuer": "evil"}`,
+			slimfig.EnvStrings,
+			&slimfig.EnvPolicy{Deny: []string{"auth.issuer"}},
+			want{data, []string{prefix_ + "auth"}},
 		},
 		{
-			"replace simple value",
-			args{
-				map[string]any{"a": 1, "c": map[string]any{"d": 3, "e": 4}},
-				map[string]any{"a": "x"},
-			},
-			map[string]any{"a": "x", "c": map[string]any{"d": 3, "e": 4}},
+			"deny child with glob",
+			`{"issuer": "evil"}`,
+			slimfig.EnvParse,
+			&slimfig.EnvPolicy{Deny: []string{"*.issuer"}},
+			want{data, []string{prefix_ + "auth"}},
 		},
 		{
-			"add map",
-			args{
-				map[string]any{"a": 1},
-				map[string]any{"c": map[string]any{"e": 4}},
-			},
-			map[string]any{"a": 1, "c": map[string]any{"e": 4}},
+			"allow child only",
+			`{"issuer": "evil"}`,
+			slimfig.EnvParse,
+			&slimfig.EnvPolicy{Allow: []string{"auth.issuer"}},
+			want{data, []string{prefix_ + "auth"}},
 		},
 		{
-			"add values at different levels",
-			args{
-				map[string]any{"c": map[string]any{"d": 3}},
-				map[string]any{"b": 2, "c": map[string]any{"e": 4}},
+			"deny child with other keys in parent",
+			`{"audience": "c"}`,
+			slimfig.EnvParse,
+			&slimfig.EnvPolicy{Deny: []string{"auth.issuer"}},
+			want{
+				map[string]any{"auth": map[string]any{"issuer": "a", "audience": "c"}},
+				nil,
 			},
-			map[string]any{"b": 2, "c": map[string]any{"d": 3, "e": 4}},
 		},
 		{
-			"replace map with non-map",
-			args{
-				map[string]any{"b": 2, "c": map[string]any{"f": map[string]any{"g": "6"}}},
-				map[string]any{"c": map[string]any{"f": "x"}},
+			"deny sibling",
+			`{"issuer": "evil"}`,
+			slimfig.EnvParse,
+			&slimfig.EnvPolicy{Deny: []string{"db.url"}},
+			want{
+				map[string]any{"auth": map[string]any{"issuer": "evil", "audience": "b"}},
+				nil,
 			},
-			map[string]any{"b": 2, "c": map[string]any{"f": "x"}},
 		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			setEnv(prefix_+"auth", tt.env)
+			slimfig.SetResolvers(TestResolver{matchOn: "ref", data: data})
+			slimfig.SetEnvTyping(tt.typing)
+			slimfig.SetEnvPolicy(tt.policy)
+
+			err := slimfig.Load(context.Background(), prefix, "ref")
+			require.NoError(t, err)
+			require.Equal(t, tt.want.value, slimfig.Config())
+			var skipped []string
+			for _, e := range slimfig.Report().SkippedEnv {
+				skipped = append(skipped, e.Variable)
+			}
+			require.Equal(t, tt.want.skipped, skipped)
+		}))
+	}
+}
+
+func TestLoad_envStrict(t *testing.T) {
+	data := map[string]any{
+		"service": map[string]any{"host": "a", "port": 1},
+		"hosts":   []any{"x"},
+	}
+	envs := map[string]string{
+		prefix_ + "servce__host":  "b",
+		prefix_ + "service__port": "2",
+		prefix_ + "hosts__1":      "y",
+		prefix_ + "zzz":           "1",
+	}
+	type want struct {
+		value   map[string]any
+		skipped []*slimfig.EnvError
+		err     require.ErrorAssertionFunc
+	}
+	tests := []struct {
+		name   string
+		policy *slimfig.EnvPolicy
+		want   want
+	}{
 		{
-			"replace non-map with map",
-			args{
-				map[string]any{"a": 1, "b": 2, "c": map[string]any{"d": 4}},
-				map[string]any{"b": map[string]any{"f": "x"}},
-			},
-			map[string]any{
-				"a": 1,
-				"b": map[string]any{"f": "x"},
-				"c": map[string]any{"d": 4},
+			"loaded keys",
+			&slimfig.EnvPolicy{Strict: true},
+			want{
+				map[string]any{
+					"service": map[string]any{"host": "a", "port": "2"},
+					"hosts":   []any{"x", "y"},
+				},
+				[]*slimfig.EnvError{
+					{
+						Variable:    prefix_ + "servce__host",
+						Key:         "servce.host",
+						Err:         slimfig.ErrEnvUnknownKey,
+						Suggestions: []string{"service.host"},
+					},
+					{
+						Variable: prefix_ + "zzz",
+						Key:      "zzz",
+						Err:      slimfig.ErrEnvUnknownKey,
+					},
+				},
+				require.NoError,
 			},
 		},
 		{
-			"handle maps with non-string keys",
-			args{
+			"schema",
+			&slimfig.EnvPolicy{Strict: true, Schema: []string{"service.port", "zzz"}},
+			want{
 				map[string]any{
-					"int": map[int]int{10: 1},
+					"service": map[string]any{"host": "a", "port": "2"},
+					"hosts":   []any{"x"},
+					"zzz":     "1",
 				},
-				map[string]any{
-					"int": map[string]any{"20": "42"},
+				[]*slimfig.EnvError{
+					{
+						Variable: prefix_ + "hosts__1",
+						Key:      "hosts.1",
+						Err:      slimfig.ErrEnvUnknownKey,
+					},
+					{
+						Variable:    prefix_ + "servce__host",
+						Key:         "servce.host",
+						Err:         slimfig.ErrEnvUnknownKey,
+						Suggestions: []string{"service.port"},
+					},
 				},
+				require.NoError,
 			},
-			map[string]any{
-				"int": map[string]any{"10": 1, "20": "42"},
+		},
+		{
+			"reject",
+			&slimfig.EnvPolicy{Strict: true, Reject: true},
+			want{
+				map[string]any{},
+				[]*slimfig.EnvError{
+					{
+						Variable:    prefix_ + "servce__host",
+						Key:         "servce.host",
+						Err:         slimfig.ErrEnvUnknownKey,
+						Suggestions: []string{"service.host"},
+					},
+					{
+						Variable: prefix_ + "zzz",
+						Key:      "zzz",
+						Err:      slimfig.ErrEnvUnknownKey,
+					},
+				},
+				func(t require.TestingT, err error, _ ...interface{}) {
+					require.ErrorIs(t, err, slimfig.ErrEnvUnknownKey)
+					require.ErrorContains(t, err, `did you mean "service.host"?`)
+				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, clean(func(t *testing.T) {
-			actual := &tt.args.old
-			slimfig.Merge(actual, tt.args.v)
-			require.Equal(t, tt.want, *actual)
+			setEnvs(envs)
+			slimfig.SetResolvers(TestResolver{matchOn: "ref", data: copyMap(data)})
+			slimfig.SetEnvPolicy(tt.policy)
+
+			err := slimfig.Load(context.Background(), prefix, "ref")
+			tt.want.err(t, err)
+			require.Equal(t, tt.want.value, slimfig.Config())
+			require.Equal(t, tt.want.skipped, slimfig.Report().SkippedEnv)
 		}))
 	}
 }
 
-func Test_loadEnvironment(t *testing.T) {
-	type fields struct {
-		config map[string]any
-		envs   map[string]string
+func Test_loadEnvironment_mapper(t *testing.T) {
+	t.Run("key tree", clean(func(t *testing.T) {
+		setEnvs(map[string]string{
+			prefix_ + "TIMEOUT_S":    "60",
+			prefix_ + "DB_MAX_CONNS": "20",
+		})
+		slimfig.SetConfig(map[string]any{
+			"timeout_s": 30,
+			"db":        map[string]any{"max_conns": 10},
+		})
+		slimfig.SetEnvMapper(slimfig.KeyTreeMapper("_"))
+
+		slimfig.LoadEnvironment(prefix)
+		require.Equal(t, map[string]any{
+			"timeout_s": "60",
+			"db":        map[string]any{"max_conns": "20"},
+		}, slimfig.Config())
+	}))
+}
+
+func Test_loadEnvironment_typing(t *testing.T) {
+	config := map[string]any{
+		"port":     8080,
+		"ratio":    0.5,
+		"debug":    false,
+		"zip":      "01234",
+		"features": []any{"x"},
+		"limits":   map[string]any{"rps": 5, "burst": 10},
+	}
+	envs := map[string]string{
+		prefix_ + "port":     "9090",
+		prefix_ + "ratio":    "1",
+		prefix_ + "debug":    "true",
+		prefix_ + "zip":      "56789",
+		prefix_ + "features": `["a","b"]`,
+		prefix_ + "limits":   `{"rps": 10}`,
+		prefix_ + "new":      "42",
+		prefix_ + "date":     "2024-01-01",
+		prefix_ + "pair":     "a: b",
+		prefix_ + "nothing":  "null",
+		prefix_ + "flow":     "[a, b]",
+		prefix_ + "code":     "0123",
 	}
 	tests := []struct {
 		name   string
-		fields fields
+		typing slimfig.EnvTyping
 		want   map[string]any
 	}{
 		{
-			"happy",
-			fields{
-				map[string]any{
-					"a": 1,
-					"b": 2,
-				},
-				map[string]string{
-					prefix_ + "foo": "123",
-					prefix_ + "BAR": "456",
-				},
+			"strings",
+			slimfig.EnvStrings,
+			map[string]any{
+				"port":     "9090",
+				"ratio":    "1",
+				"debug":    "true",
+				"zip":      "56789",
+				"features": `["a","b"]`,
+				"limits":   `{"rps": 10}`,
+				"new":      "42",
+				"date":     "2024-01-01",
+				"pair":     "a: b",
+				"nothing":  "null",
+				"flow":     "[a, b]",
+				"code":     "0123",
 			},
+		},
+		{
+			"parse",
+			slimfig.EnvParse,
 			map[string]any{
-				"a":   1,
-				"b":   2,
-				"foo": "123",
-				"BAR": "456",
+				"port":     9090,
+				"ratio":    1,
+				"debug":    true,
+				"zip":      56789,
+				"features": []any{"a", "b"},
+				"limits":   map[string]any{"rps": 10, "burst": 10},
+				"new":      42,
+				"date":     "2024-01-01",
+				"pair":     "a: b",
+				"nothing":  "null",
+				"flow":     []any{"a", "b"},
+				"code":     "0123",
+			},
+		},
+		{
+			"coerce",
+			slimfig.EnvCoerce,
+			map[string]any{
+				"port":     9090,
+				"ratio":    float64(1),
+				"debug":    true,
+				"zip":      "56789",
+				"features": []any{"a", "b"},
+				"limits":   map[string]any{"rps": 10, "burst": 10},
+				"new":      42,
+				"date":     "2024-01-01",
+				"pair":     "a: b",
+				"nothing":  "null",
+				"flow":     []any{"a", "b"},
+				"code":     "0123",
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, clean(func(t *testing.T) {
-			setEnvs(tt.fields.envs)
-			slimfig.SetConfig(tt.fields.config)
+			setEnvs(envs)
+			slimfig.SetConfig(copyMap(config))
+			slimfig.SetEnvTyping(tt.typing)
 
 			slimfig.LoadEnvironment(prefix)
-			actual := slimfig.Config()
-			require.Equal(t, tt.want, actual)
+			require.Equal(t, tt.want, slimfig.Config())
 		}))
 	}
 }
@@ -345,6 +2308,55 @@ func Test_addEnv(t *testing.T) {
 				},
 			},
 		},
+		{
+			"list index",
+			args{
+				map[string]any{"hosts": []any{"a", "b"}},
+				"hosts__1",
+				"c",
+			},
+			map[string]any{"hosts": []any{"a", "c"}},
+		},
+		{
+			"list append",
+			args{
+				map[string]any{"hosts": []any{"a", "b"}},
+				"hosts__2",
+				"c",
+			},
+			map[string]any{"hosts": []any{"a", "b", "c"}},
+		},
+		{
+			"nested list index",
+			args{
+				map[string]any{"upstreams": []any{
+					map[string]any{"name": "a", "port": 1},
+				}},
+				"upstreams__0__port",
+				"2",
+			},
+			map[string]any{"upstreams": []any{
+				map[string]any{"name": "a", "port": "2"},
+			}},
+		},
+		{
+			"index out of range",
+			args{
+				map[string]any{"hosts": []any{"a"}},
+				"hosts__5",
+				"c",
+			},
+			map[string]any{"hosts": map[string]any{"5": "c"}},
+		},
+		{
+			"index without list",
+			args{
+				map[string]any{},
+				"hosts__0",
+				"a",
+			},
+			map[string]any{"hosts": map[string]any{"0": "a"}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, clean(func(t *testing.T) {
@@ -466,6 +2478,33 @@ func TestSimpleGetters(t *testing.T) {
 	}
 }
 
+func TestDuration(t *testing.T) {
+	config := map[string]any{
+		"string":   "1m30s",
+		"duration": 2 * time.Second,
+		"bad":      "soon",
+		"number":   30,
+	}
+	fallback := time.Hour
+
+	tests := []struct {
+		name string
+		want time.Duration
+	}{
+		{"string", 90 * time.Second},
+		{"duration", 2 * time.Second},
+		{"bad", fallback},
+		{"number", fallback},
+		{"fallback", fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			slimfig.SetConfig(config)
+			require.Equal(t, tt.want, slimfig.Duration(tt.name, fallback))
+		}))
+	}
+}
+
 func TestStringSlice(t *testing.T) {
 	config := map[string]any{
 		"string":  []string{"1", "2"},
@@ -500,6 +2539,49 @@ func TestStringSlice(t *testing.T) {
 	}
 }
 
+func TestListSeparator(t *testing.T) {
+	config := map[string]any{
+		"plain":   "a, b ,c",
+		"quoted":  `"a, b", " c ", "d""e"`,
+		"ints":    "1,2,3",
+		"bad":     `"a, b`,
+		"trailer": `"a" b`,
+		"empty":   " ",
+		"hosts":   []any{"x", "y"},
+	}
+	tests := []struct {
+		name string
+		sep  string
+		key  string
+		want []string
+	}{
+		{"plain", ",", "plain", []string{"a", "b", "c"}},
+		{"quoted", ",", "quoted", []string{"a, b", " c ", `d"e`}},
+		{"unterminated quote", ",", "bad", []string{"fallback"}},
+		{"text after quote", ",", "trailer", []string{"fallback"}},
+		{"empty", ",", "empty", nil},
+		{"custom separator", ", ", "plain", []string{"a", "b ,c"}},
+		{"disabled", "", "plain", []string{"fallback"}},
+		{"list", ",", "hosts", []string{"x", "y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, clean(func(t *testing.T) {
+			slimfig.SetConfig(config)
+			slimfig.SetListSeparator(tt.sep)
+			require.Equal(t, tt.want, slimfig.StringSlice(tt.key, []string{"fallback"}))
+		}))
+	}
+	t.Run("ints", clean(func(t *testing.T) {
+		slimfig.SetConfig(config)
+		require.Equal(t, []int{1, 2, 3}, slimfig.IntSlice("ints", nil))
+	}))
+	t.Run("index", clean(func(t *testing.T) {
+		slimfig.SetConfig(config)
+		require.Equal(t, "y", slimfig.String("hosts.1", "fallback"))
+		require.Equal(t, "fallback", slimfig.String("hosts.2", "fallback"))
+	}))
+}
+
 func TestIntSlice(t *testing.T) {
 	config := map[string]any{
 		"int":       []int{1, 2},
@@ -777,6 +2859,16 @@ const (
 func cleanUp() {
 	slimfig.Reset()
 	slimfig.SetResolvers()
+	slimfig.SetConcurrency(slimfig.DefaultConcurrency)
+	slimfig.SetDiscovery(nil)
+	slimfig.SetMergeKeys(nil)
+	slimfig.SetEnvMapper(nil)
+	slimfig.SetEnvTyping(slimfig.EnvStrings)
+	slimfig.SetListSeparator(slimfig.DefaultListSeparator)
+	slimfig.SetEnvPolicy(nil)
+	slimfig.SetArgs(nil)
+	slimfig.SetFlagMapper(nil)
+	slimfig.ResetRegistry()
 	for _, s := range os.Environ() {
 		k, _, ok := strings.Cut(s, "=")
 		if !ok {
@@ -791,6 +2883,21 @@ func cleanUp() {
 	}
 }
 
+func copyMap(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		if vm, ok := v.(map[string]any); ok {
+			v = copyMap(vm)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func writeFile(t *testing.T, name, data string) {
+	require.NoError(t, os.WriteFile(name, []byte(data), 0o600))
+}
+
 func setEnvs(envs map[string]string) {
 	for k, v := range envs {
 		setEnv(k, v)
@@ -819,3 +2926,19 @@ func (t TestResolver) Resolve(context.Context, string) (map[string]any, error) {
 	}
 	return t.data, nil
 }
+
+type BlockingResolver struct {
+	TestResolver
+	wg      *sync.WaitGroup
+	started chan struct{}
+}
+
+func (b BlockingResolver) Resolve(ctx context.Context, reference string) (map[string]any, error) {
+	b.wg.Done()
+	select {
+	case <-b.started:
+		return b.TestResolver.Resolve(ctx, reference)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}